@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/confload"
+	"bench_dispatch/datamodels"
+)
+
+// Formats des jeux d'adresses
+const (
+	addressSimple  = "simple"  // Adresse;Longitude;Latitude, comme Marseille.csv
+	addressBAN     = "ban"     // Export CSV de la Base Adresse Nationale
+	addressGeoJSON = "geojson" // FeatureCollection de Point
+	addressCSV     = "csv"     // CSV avec colonnes configurées
+)
+
+// defaultAddresses : Jeu chargé quand la conf n'a pas de section [Addresses.nom]
+var defaultAddresses = datamodels.AddressSource{Format: addressSimple, File: "Marseille.csv"}
+
+// maxRejectLogs : Nb de lignes rejetées détaillées dans les logs par jeu
+const maxRejectLogs = 5
+
+// addressSet : Adresses d'un jeu, avec son emprise pour retrouver le jeu d'un point
+type addressSet struct {
+	name                           string
+	list                           []datamodels.Address
+	minLat, maxLat, minLng, maxLng float64
+}
+
+func (s *addressSet) add(a datamodels.Address) {
+	lat, lng := a.Coord.Latitude, a.Coord.Longitude
+	if len(s.list) == 0 {
+		s.minLat, s.maxLat, s.minLng, s.maxLng = lat, lat, lng, lng
+	}
+	if lat < s.minLat {
+		s.minLat = lat
+	}
+	if lat > s.maxLat {
+		s.maxLat = lat
+	}
+	if lng < s.minLng {
+		s.minLng = lng
+	}
+	if lng > s.maxLng {
+		s.maxLng = lng
+	}
+	s.list = append(s.list, a)
+}
+
+func (s *addressSet) contains(c datamodels.Coordinates) bool {
+	return c.Latitude >= s.minLat && c.Latitude <= s.maxLat && c.Longitude >= s.minLng && c.Longitude <= s.maxLng
+}
+
+// addressSets : Jeux chargés, plusieurs pour une charge multi-villes
+var addressSets []*addressSet
+
+// addressLoader : Lecture d'un jeu, compte les enregistrements écartés
+type addressLoader struct {
+	name    string
+	source  datamodels.AddressSource
+	set     *addressSet
+	rejects int
+}
+
+func (l *addressLoader) reject(line int, format string, vars ...interface{}) {
+	l.rejects++
+	if l.rejects <= maxRejectLogs {
+		clog.Warn("main", "Addresses", "%s line %d: %s", l.name, line, fmt.Sprintf(format, vars...))
+	}
+}
+
+// validateAddress : Coordonnées dans les bornes WGS84 et nom renseigné
+func validateAddress(a datamodels.Address) error {
+	c := a.Coord
+	switch {
+	case strings.TrimSpace(a.Name) == "":
+		return fmt.Errorf("empty name")
+	case c.Latitude < -90 || c.Latitude > 90:
+		return fmt.Errorf("latitude %f out of range", c.Latitude)
+	case c.Longitude < -180 || c.Longitude > 180:
+		return fmt.Errorf("longitude %f out of range", c.Longitude)
+	case c.Latitude == 0 && c.Longitude == 0:
+		return fmt.Errorf("null coordinates")
+	}
+	return nil
+}
+
+func (l *addressLoader) add(line int, a datamodels.Address) {
+	if err := validateAddress(a); err != nil {
+		l.reject(line, "%s", err)
+		return
+	}
+	l.set.add(a)
+}
+
+// csvColumns : Colonnes du nom (jointes par un espace), de la latitude et de la longitude
+type csvColumns struct {
+	name     []int
+	lat, lng int
+}
+
+// column : Index d'une colonne, par son nom dans l'en-tête ou son numéro à partir de 1
+func column(ref string, header []string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if header != nil {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), ref) {
+				return i, nil
+			}
+		}
+	}
+	n, err := strconv.Atoi(ref)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("unknown column %q", ref)
+	}
+	return n - 1, nil
+}
+
+func (l *addressLoader) columns(header []string) (csvColumns, error) {
+	var cols csvColumns
+	var err error
+	for _, ref := range strings.Split(l.source.NameColumn, ",") {
+		i, err := column(ref, header)
+		if err != nil {
+			return cols, err
+		}
+		cols.name = append(cols.name, i)
+	}
+	if cols.lat, err = column(l.source.LatColumn, header); err != nil {
+		return cols, err
+	}
+	if cols.lng, err = column(l.source.LngColumn, header); err != nil {
+		return cols, err
+	}
+	return cols, nil
+}
+
+// loadCSV : Jeu CSV, simple et ban sont des colonnes prédéfinies
+func (l *addressLoader) loadCSV() error {
+	f, err := os.Open(l.source.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = ';'
+	if sep := l.source.Separator; sep != "" {
+		r.Comma = []rune(sep)[0]
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	var header []string
+	if l.source.Header {
+		if header, err = r.Read(); err != nil {
+			return fmt.Errorf("header: %s", err)
+		}
+	}
+	cols, err := l.columns(header)
+	if err != nil {
+		return err
+	}
+
+	line := 1
+	if header != nil {
+		line++
+	}
+	for ; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			l.reject(line, "%s", err)
+			continue
+		}
+
+		var name []string
+		for _, i := range cols.name {
+			if i < len(record) && strings.TrimSpace(record[i]) != "" {
+				name = append(name, strings.TrimSpace(record[i]))
+			}
+		}
+		if cols.lat >= len(record) || cols.lng >= len(record) {
+			l.reject(line, "%d fields", len(record))
+			continue
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(record[cols.lat]), 64)
+		if err != nil {
+			l.reject(line, "latitude: %s", err)
+			continue
+		}
+		lng, err := strconv.ParseFloat(strings.TrimSpace(record[cols.lng]), 64)
+		if err != nil {
+			l.reject(line, "longitude: %s", err)
+			continue
+		}
+		l.add(line, datamodels.Address{Name: strings.Join(name, " "), Coord: datamodels.Coordinates{Latitude: lat, Longitude: lng}})
+	}
+}
+
+type geoJSONPoints struct {
+	Features []struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// loadGeoJSON : Features Point d'une FeatureCollection, nom dans la propriété NameColumn
+func (l *addressLoader) loadGeoJSON() error {
+	data, err := ioutil.ReadFile(l.source.File)
+	if err != nil {
+		return err
+	}
+	var fc geoJSONPoints
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return err
+	}
+
+	for i, f := range fc.Features {
+		if f.Geometry.Type != "Point" {
+			continue
+		}
+		if len(f.Geometry.Coordinates) < 2 {
+			l.reject(i+1, "point without coordinates")
+			continue
+		}
+		var name []string
+		for _, p := range strings.Split(l.source.NameColumn, ",") {
+			if v, has := f.Properties[strings.TrimSpace(p)]; has && v != nil {
+				name = append(name, fmt.Sprint(v))
+			}
+		}
+		// GeoJSON : [longitude, latitude]
+		l.add(i+1, datamodels.Address{Name: strings.Join(name, " "), Coord: datamodels.Coordinates{
+			Longitude: f.Geometry.Coordinates[0],
+			Latitude:  f.Geometry.Coordinates[1],
+		}})
+	}
+	return nil
+}
+
+// presetColumns : Colonnes des formats connus, les valeurs de la conf l'emportent
+func presetColumns(s *datamodels.AddressSource) error {
+	var preset datamodels.AddressSource
+	switch s.Format {
+	case "", addressSimple:
+		s.Format = addressSimple
+		s.Header = true
+		preset = datamodels.AddressSource{NameColumn: "1", LngColumn: "2", LatColumn: "3"}
+	case addressBAN:
+		s.Header = true
+		preset = datamodels.AddressSource{NameColumn: "numero,rep,nom_voie,code_postal,nom_commune", LngColumn: "lon", LatColumn: "lat"}
+	case addressGeoJSON:
+		preset = datamodels.AddressSource{NameColumn: "label,name"}
+	case addressCSV:
+	default:
+		return fmt.Errorf("unknown format %q", s.Format)
+	}
+
+	if s.NameColumn == "" {
+		s.NameColumn = preset.NameColumn
+	}
+	if s.LatColumn == "" {
+		s.LatColumn = preset.LatColumn
+	}
+	if s.LngColumn == "" {
+		s.LngColumn = preset.LngColumn
+	}
+	if s.Format == addressCSV && (s.NameColumn == "" || s.LatColumn == "" || s.LngColumn == "") {
+		return fmt.Errorf("csv format needs NameColumn, LatColumn and LngColumn")
+	}
+	return nil
+}
+
+// loadAddressSet : Charge et valide un jeu d'adresses
+func loadAddressSet(name string, source datamodels.AddressSource) (*addressSet, error) {
+	if source.File == "" {
+		return nil, fmt.Errorf("%s: no file", name)
+	}
+	if err := presetColumns(&source); err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+
+	l := &addressLoader{name: name, source: source, set: &addressSet{name: name}}
+	var err error
+	if source.Format == addressGeoJSON {
+		err = l.loadGeoJSON()
+	} else {
+		err = l.loadCSV()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	if len(l.set.list) == 0 {
+		return nil, fmt.Errorf("%s: no valid address in %s (%d rejected)", name, source.File, l.rejects)
+	}
+	clog.Info("main", "Addresses", "%s: %d addresses loaded from %s, %d rejected", name, len(l.set.list), source.File, l.rejects)
+	return l.set, nil
+}
+
+// loadAddresses : Charge les jeux [Addresses.nom] du scénario courant, Marseille.csv par défaut
+func loadAddresses() {
+	names := confload.Children("Addresses")
+	for _, name := range names {
+		var source datamodels.AddressSource
+		if err := confload.LoadSection("Addresses."+name, &source); err != nil {
+			clog.Fatal("main", "Addresses", err)
+		}
+		if source.Scenario != "" && source.Scenario != conf.Bench.Scenario {
+			continue
+		}
+		set, err := loadAddressSet(name, source)
+		if err != nil {
+			clog.Fatal("main", "Addresses", err)
+		}
+		addressSets = append(addressSets, set)
+	}
+	if len(names) == 0 {
+		set, err := loadAddressSet("default", defaultAddresses)
+		if err != nil {
+			clog.Fatal("main", "Addresses", err)
+		}
+		addressSets = append(addressSets, set)
+	}
+	if len(addressSets) == 0 {
+		clog.Fatal("main", "Addresses", fmt.Errorf("no address set for scenario %q", conf.Bench.Scenario))
+	}
+
+	address = nil
+	for _, set := range addressSets {
+		address = append(address, set.list...)
+	}
+	nbAdress = len(address)
+}
+
+func getNewAdress() datamodels.Address {
+	return address[rand.Intn(nbAdress)]
+}
+
+// destinationAddress : Destination dans le même jeu que la prise en charge,
+// une charge multi-villes ne crée pas de course d'une ville à l'autre
+func destinationAddress(from datamodels.Address) datamodels.Address {
+	if len(addressSets) > 1 {
+		for _, set := range addressSets {
+			if set.contains(from.Coord) {
+				return chooseAddress(set.list, set.contains)
+			}
+		}
+	}
+	return chooseAddress(nil, nil)
+}
+
+// chooseAddress : Adresse tirée selon la densité si elle est configurée, uniformément sinon.
+// Le tirage est limité à pool (toutes les adresses si vide), within vérifiant qu'un tirage
+// par densité tombe dans le même périmètre.
+func chooseAddress(pool []datamodels.Address, within func(datamodels.Coordinates) bool) datamodels.Address {
+	if density != nil {
+		for try := 0; try < maxSnapTries; try++ {
+			a := densityAddress()
+			if within == nil || within(a.Coord) {
+				return a
+			}
+		}
+	}
+	if len(pool) == 0 {
+		return getNewAdress()
+	}
+	return pool[rand.Intn(len(pool))]
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+)
+
+// authCase : Cas de test négatif de l'authentification
+type authCase struct {
+	Name     string
+	Expected datamodels.Error
+	Run      func(p *probeConn) (*datamodels.Response, error)
+	Skip     string // Raison pour laquelle le cas n'est pas joué
+}
+
+// authResult : Résultat d'un cas de test
+type authResult struct {
+	Case     authCase
+	Response *datamodels.Response
+	Err      error
+}
+
+func (r authResult) passed() bool {
+	return r.Err == nil && r.Response != nil && r.Response.Status.ID == r.Case.Expected.ID
+}
+
+// AuthSuite : Suite de tests négatifs de l'authentification
+type AuthSuite struct {
+	results []authResult
+}
+
+func loginWith(token string, id int) datamodels.Login {
+	return datamodels.Login{
+		ID:    id,
+		Name:  "bench-auth",
+		State: datamodels.Offline,
+		Token: token,
+	}
+}
+
+// badSignature : Altère la signature d'un jeton JWT sans le rendre illisible
+func badSignature(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || len(parts[2]) == 0 {
+		return token + "A"
+	}
+	sig := []byte(parts[2])
+	if sig[0] == 'A' {
+		sig[0] = 'B'
+	} else {
+		sig[0] = 'A'
+	}
+	parts[2] = string(sig)
+	return strings.Join(parts, ".")
+}
+
+func authCases() []authCase {
+	cases := []authCase{
+		{
+			Name:     "Location before login",
+			Expected: datamodels.ERR_NOT_LOGGED,
+			Run: func(p *probeConn) (*datamodels.Response, error) {
+				loc := datamodels.UpdateDriverLocation{
+					Coord:       getNewAdress().Coord,
+					VehicleType: datamodels.Berline,
+				}
+				return p.call("UpdateDriverLocation", loc, probeTimeout())
+			},
+		},
+		{
+			Name:     "Malformed token",
+			Expected: datamodels.ERR_UNREADABLE_TOKEN,
+			Run: func(p *probeConn) (*datamodels.Response, error) {
+				return p.call("Login", loginWith("not-a-jwt-token", 1), probeTimeout(), "LoginResponse")
+			},
+		},
+		{
+			Name:     "Badly signed token",
+			Expected: datamodels.ERR_BAD_TOKEN,
+			Run: func(p *probeConn) (*datamodels.Response, error) {
+				return p.call("Login", loginWith(badSignature(conf.Auth.Token), 1), probeTimeout(), "LoginResponse")
+			},
+		},
+		{
+			Name:     "Unknown user",
+			Expected: datamodels.ERR_UNKNOWN_USER,
+			Run: func(p *probeConn) (*datamodels.Response, error) {
+				return p.call("Login", loginWith(conf.Auth.UnknownUserToken, 1), probeTimeout(), "LoginResponse")
+			},
+		},
+		{
+			Name:     "Empty payload",
+			Expected: datamodels.ERR_EMPTY_REQUEST,
+			Run: func(p *probeConn) (*datamodels.Response, error) {
+				if err := p.sendRaw(nil); err != nil {
+					return nil, err
+				}
+				return p.expect(0, probeTimeout())
+			},
+		},
+	}
+
+	if conf.Auth.UnknownUserToken == "" {
+		cases[3].Skip = "Auth.UnknownUserToken not set"
+	}
+	return cases
+}
+
+// runAuthSuite : Joue chaque cas sur une connexion dédiée et retourne le code de sortie
+func runAuthSuite(u url.URL) int {
+	suite := &AuthSuite{}
+	addReporter(suite)
+
+	for _, c := range authCases() {
+		res := authResult{Case: c}
+		if c.Skip == "" {
+			p, err := dialProbe(u)
+			if err != nil {
+				clog.Fatal("auth", "Connect", err)
+			}
+			res.Response, res.Err = c.Run(p)
+			p.close()
+			clog.Trace("auth", c.Name, "%s", passFail(res.passed()))
+		}
+		suite.results = append(suite.results, res)
+	}
+
+	return writeReport()
+}
+
+// Report : Résultat par cas de test
+func (s *AuthSuite) Report(w io.Writer) bool {
+	ok := true
+
+	reportTitle(w, "Authentication negative tests")
+	for _, r := range s.results {
+		expected := fmt.Sprintf("%d %s", r.Case.Expected.ID, r.Case.Expected.Message)
+		switch {
+		case r.Case.Skip != "":
+			fmt.Fprintf(w, "SKIP %-22s expected %-28s (%s)\n", r.Case.Name, expected, r.Case.Skip)
+		case r.Err != nil:
+			ok = false
+			fmt.Fprintf(w, "FAIL %-22s expected %-28s got error: %s\n", r.Case.Name, expected, r.Err)
+		default:
+			got := datamodels.ErrorFromCode(r.Response.Status.ID)
+			if !r.passed() {
+				ok = false
+			}
+			fmt.Fprintf(w, "%s %-22s expected %-28s got %d %s (%s: %s)\n", passFail(r.passed()), r.Case.Name, expected,
+				got.ID, got.Message, r.Response.Method, r.Response.Status.Message)
+		}
+	}
+	return ok
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/confload"
+	"bench_dispatch/datamodels"
+)
+
+// Lois de tirage du temps de réaction
+const (
+	thinkUniform     = "uniform"
+	thinkExponential = "exponential"
+	thinkNormal      = "normal"
+)
+
+// Réactions d'un driver à un NewRide
+const (
+	offerAccept  = "accept"  // AcceptRide après le temps de réaction
+	offerDecline = "decline" // Pas d'AcceptRide après réflexion
+	offerIgnore  = "ignore"  // Offre jamais lue
+	offerLate    = "late"    // AcceptRide après l'expiration de l'offre
+	offerBusy    = "busy"    // Driver occupé ou déjà en réflexion sur une autre offre
+)
+
+// behaviour : Profil de comportement d'un groupe de drivers, section [Profile.nom]
+type behaviour struct {
+	datamodels.Behaviour
+	Name string
+}
+
+// defaultBehaviour : Accepte toutes les offres immédiatement
+var defaultBehaviour = &behaviour{
+	Behaviour: datamodels.Behaviour{AcceptPercent: 100, ThinkDistribution: thinkUniform},
+	Name:      "default",
+}
+
+// behaviours : Profils de la conf par nom
+var behaviours = map[string]*behaviour{}
+
+// loadBehaviours : Charge les sections [Profile.nom] du fichier de conf
+func loadBehaviours() {
+	for _, name := range confload.Children("Profile") {
+		b := datamodels.Behaviour{AcceptPercent: 100, ThinkDistribution: thinkUniform}
+		if err := confload.LoadSection("Profile."+name, &b); err != nil {
+			clog.Fatal("main", "Profile", err)
+		}
+		switch b.ThinkDistribution {
+		case thinkUniform, thinkExponential, thinkNormal:
+		default:
+			clog.Fatal("main", "Profile", fmt.Errorf("profile %s: unknown think distribution %q", name, b.ThinkDistribution))
+		}
+		behaviours[name] = &behaviour{Behaviour: b, Name: name}
+	}
+	clog.Info("main", "Profile", "%d behaviour profiles", len(behaviours))
+}
+
+// behaviourFor : Profil nommé, profil par défaut si le nom est vide
+func behaviourFor(name string) (*behaviour, error) {
+	if name == "" {
+		return defaultBehaviour, nil
+	}
+	b, has := behaviours[name]
+	if !has {
+		return nil, fmt.Errorf("unknown behaviour profile %q", name)
+	}
+	return b, nil
+}
+
+// react : Tire la réaction à une offre
+func (b *behaviour) react() string {
+	n := rand.Intn(100)
+	switch {
+	case n < b.IgnorePercent:
+		return offerIgnore
+	case n < b.IgnorePercent+b.LatePercent:
+		return offerLate
+	case rand.Intn(100) < b.AcceptPercent:
+		return offerAccept
+	}
+	return offerDecline
+}
+
+// think : Temps de réaction selon la loi du profil, borné par ThinkMin et ThinkMax
+func (b *behaviour) think() time.Duration {
+	min, max := float64(b.ThinkMin), float64(b.ThinkMax)
+	if max <= min {
+		return time.Duration(b.ThinkMin) * time.Millisecond
+	}
+
+	var ms float64
+	switch b.ThinkDistribution {
+	case thinkExponential:
+		ms = min + rand.ExpFloat64()*(max-min)/4
+	case thinkNormal:
+		ms = (min+max)/2 + rand.NormFloat64()*(max-min)/6
+	default:
+		ms = min + rand.Float64()*(max-min)
+	}
+	if ms < min {
+		ms = min
+	}
+	if ms > max {
+		ms = max
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// offerExpiry : Fin de validité d'une offre, date zéro si inconnue
+func offerExpiry(ride datamodels.RideData, received time.Time) time.Time {
+	if ride.ValidUntil != "" {
+		if t, err := datamodels.GetDateFromIOS(ride.ValidUntil); err == nil {
+			return t
+		}
+	}
+	if conf.Dispatch.OfferValidity > 0 {
+		return received.Add(time.Duration(conf.Dispatch.OfferValidity) * time.Second)
+	}
+	return time.Time{}
+}
+
+// considerOffer : Réaction du driver à un NewRide selon le profil de son groupe
+func (d *Driver) considerOffer(ride datamodels.RideData, received time.Time, from trigger) {
+	b := d.Group.behaviour
+
+	d.mu.Lock()
+	if d.DriverState != datamodels.Free || d.offerPending {
+		d.mu.Unlock()
+		d.hub.behaviours.reacted(b, offerBusy, 0)
+		return
+	}
+
+	reaction := b.react()
+	var delay time.Duration
+	switch reaction {
+	case offerAccept:
+		delay = b.think()
+	case offerLate:
+		expiry := offerExpiry(ride, received)
+		if expiry.IsZero() {
+			reaction = offerIgnore
+			break
+		}
+		delay = expiry.Sub(received) + time.Duration(b.LateDelay)*time.Millisecond
+	}
+	pending := reaction == offerAccept || reaction == offerLate
+	d.offerPending = pending
+	d.mu.Unlock()
+
+	d.hub.behaviours.reacted(b, reaction, delay)
+	if !pending {
+		return
+	}
+
+	expiry := offerExpiry(ride, received)
+	if delay <= 0 {
+		d.acceptOffer(ride, expiry, from)
+		return
+	}
+	time.AfterFunc(delay, func() { d.acceptOffer(ride, expiry, from) })
+}
+
+// acceptOffer : Envoi de l'AcceptRide si le driver est toujours libre
+func (d *Driver) acceptOffer(ride datamodels.RideData, expiry time.Time, from trigger) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.offerPending = false
+	if d.DriverState != datamodels.Free || !d.transition(datamodels.WaitOK, from) {
+		d.hub.behaviours.dropped(d.Group.behaviour)
+		return
+	}
+	d.offerID = ride.ID
+	d.offerSentAt = time.Now()
+	d.offerExpired = !expiry.IsZero() && d.offerSentAt.After(expiry)
+	d.waitReq = d.writeRequest("AcceptRide", datamodels.AcceptRide{ID: ride.ID, Vehicle: d.Vehicle})
+	d.hub.ledger.accepted(d, ride.ID, d.offerExpired)
+}
+
+// behaviourStats : Réactions et résultats des AcceptRide d'un profil
+type behaviourStats struct {
+	reactions  map[string]int
+	think      durations
+	dropped    int // AcceptRide abandonnés, le driver n'était plus libre
+	won, lost  int // AcceptRide envoyés dans les temps
+	expiredWon int // AcceptRide expirés acceptés par le serveur
+	expiredRej int // AcceptRide expirés refusés par le serveur
+}
+
+// BehaviourStats : Statistiques par profil de comportement
+type BehaviourStats struct {
+	mu       sync.Mutex
+	profiles map[string]*behaviourStats
+}
+
+// NewBehaviourStats : Creation des statistiques de comportement
+func NewBehaviourStats() *BehaviourStats {
+	return &BehaviourStats{
+		profiles: make(map[string]*behaviourStats),
+	}
+}
+
+func (s *BehaviourStats) profile(b *behaviour) *behaviourStats {
+	p, has := s.profiles[b.Name]
+	if !has {
+		p = &behaviourStats{reactions: make(map[string]int)}
+		s.profiles[b.Name] = p
+	}
+	return p
+}
+
+// reacted : Réaction tirée pour un NewRide
+func (s *BehaviourStats) reacted(b *behaviour, reaction string, delay time.Duration) {
+	s.mu.Lock()
+	p := s.profile(b)
+	p.reactions[reaction]++
+	if reaction == offerAccept {
+		p.think = append(p.think, delay)
+	}
+	s.mu.Unlock()
+}
+
+// dropped : Le driver n'était plus libre au moment d'accepter
+func (s *BehaviourStats) dropped(b *behaviour) {
+	s.mu.Lock()
+	s.profile(b).dropped++
+	s.mu.Unlock()
+}
+
+// answered : Réponse du serveur à un AcceptRide
+func (s *BehaviourStats) answered(b *behaviour, code int, expired bool) {
+	won := code == datamodels.ERR_SUCCESS.ID
+
+	s.mu.Lock()
+	p := s.profile(b)
+	switch {
+	case expired && won:
+		p.expiredWon++
+	case expired:
+		p.expiredRej++
+	case won:
+		p.won++
+	default:
+		p.lost++
+	}
+	s.mu.Unlock()
+}
+
+// Report : Réactions, temps de réaction et attributions par profil
+func (s *BehaviourStats) Report(w io.Writer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reportTitle(w, "Driver behaviour profiles")
+
+	names := make([]string, 0, len(s.profiles))
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	expiredWon := 0
+	for _, name := range names {
+		p := s.profiles[name]
+		fmt.Fprintf(w, "Profile %s: accept %d, decline %d, ignore %d, late %d, busy %d, dropped %d\n", name,
+			p.reactions[offerAccept], p.reactions[offerDecline], p.reactions[offerIgnore], p.reactions[offerLate], p.reactions[offerBusy], p.dropped)
+		fmt.Fprintf(w, "  Think time     %s\n", p.think.summary())
+		fmt.Fprintf(w, "  In time        won %d, lost %d\n", p.won, p.lost)
+		fmt.Fprintf(w, "  After expiry   won %d, refused %d\n", p.expiredWon, p.expiredRej)
+		expiredWon += p.expiredWon
+	}
+	fmt.Fprintf(w, "%s expired offers won: %d\n", passFail(expiredWon == 0), expiredWon)
+	return expiredWon == 0
+}
@@ -3,13 +3,11 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/csv"
-	"io"
+	"fmt"
 	"math/rand"
 	"net"
 	"net/url"
 	"os"
-	"strconv"
 	"time"
 
 	"bench_dispatch/clog"
@@ -21,6 +19,18 @@ import (
 	"github.com/mailru/easygo/netpoll"
 )
 
+// Modes du bench
+const (
+	modeLoad        = "load"
+	modeAuth        = "auth"
+	modeConformance = "conformance"
+)
+
+// Scénarios de charge
+const (
+	scenarioHerd = "herd" // Tous les drivers et toutes les prises en charge au même endroit
+)
+
 var (
 	ioTimeout = time.Millisecond * 100
 	conf      = &datamodels.ConfigData{}
@@ -28,6 +38,11 @@ var (
 	hub       *Hub
 	address   []datamodels.Address
 	nbAdress  int
+
+	herdAddress datamodels.Address
+
+	wsURL  url.URL
+	poller netpoll.Poller
 )
 
 // Deadliner : Wrapper de connection pour ajouter un timer avant chaque lecture / ecriture
@@ -50,8 +65,13 @@ func (d Deadliner) Read(p []byte) (int, error) {
 	return d.Conn.Read(p)
 }
 
-func connect(i int, u url.URL) net.Conn {
+func dial(u url.URL) (net.Conn, error) {
 	conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), u.String())
+	return conn, err
+}
+
+func connect(i int, u url.URL) net.Conn {
+	conn, err := dial(u)
 	if err != nil {
 		clog.Fatal("main", "Connect", err)
 	}
@@ -59,36 +79,39 @@ func connect(i int, u url.URL) net.Conn {
 	return conn
 }
 
-func loadCSV() int {
-	count := -2
-	csvfile, err := os.Open("Marseille.csv")
-	if err != nil {
-		clog.Fatal("main", "CSV", err)
-	}
-
-	// Parse the file
-	r := csv.NewReader(csvfile)
-	r.Comma = ';'
-	r.FieldsPerRecord = 3
-	//r := csv.NewReader(bufio.NewReader(csvfile))
-
-	for {
-		// Read each record from csv
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			clog.Fatal("main", "CSV", err)
+// listen : Ajoute un listener sur la connection du driver
+func listen(driver *Driver, conn net.Conn) {
+	desc := netpoll.Must(netpoll.HandleRead(conn))
+	driver.desc = desc
+
+	poller.Start(desc, func(ev netpoll.Event) {
+		if ev&(netpoll.EventReadHup|netpoll.EventHup) != 0 {
+			clog.File("POLLR", "ERROR", "%v", ev)
+			// Connexion perdue ou terminée par le client
+			poller.Stop(desc)
+			hub.Remove(driver)
+			return
 		}
-		long, _ := strconv.ParseFloat(record[1], 64)
-		lat, _ := strconv.ParseFloat(record[2], 64)
-		tmp := datamodels.Address{Name: record[0], Coord: datamodels.Coordinates{Longitude: long, Latitude: lat}}
-		address = append(address, tmp)
-		count++
+		// Nouveau message entrant
+		pool.Schedule(func() {
+			clog.File("POLLR", driver.Name, "Msg IN")
+			if err := driver.Receive(); err != nil {
+				// Pb de reception, la connexion est rompue
+				clog.File("R-ERR", driver.Name, "%s", err)
+				poller.Stop(desc)
+				hub.Remove(driver)
+			}
+		})
+	})
+}
+
+// unlisten : Retire le listener avant une déconnexion volontaire
+func unlisten(driver *Driver) {
+	if driver.desc != nil {
+		poller.Stop(driver.desc)
+		driver.desc.Close()
+		driver.desc = nil
 	}
-	clog.Trace("main", "Address CSV", "Loaded %d address ...", count)
-	return count
 }
 
 func getName(nb int) string {
@@ -105,29 +128,19 @@ func getName(nb int) string {
 	return scanner.Text()
 }
 
-func getNewAdress() datamodels.Address {
-	tmp := rand.Intn(nbAdress) + 1
-	return address[tmp]
+// shutdown : Ferme les connexions, exporte les trajectoires, écrit le rapport et quitte
+func shutdown() {
+	hub.disconnectAll()
+	hub.tracks.export()
+	os.Exit(writeReport())
 }
 
-func main() {
+func runLoad(u url.URL) {
 	var exit = make(chan struct{})
-	confload.Load("config.ini", conf)
-
-	clog.LogLevel = 5
-	clog.StartLogging = true
-	if conf.FileLog != "" {
-		clog.EnableFileLog(conf.FileLog)
-	}
-
-	nbAdress = loadCSV()
-
-	pool = gopool.NewPool(conf.Workers, conf.QueueSize, 10)
-	hub = NewHub(pool)
+	var err error
 
-	u := url.URL{Scheme: "ws", Host: conf.WSserver.Addr, Path: "/ws"}
-
-	poller, err := netpoll.New(nil)
+	wsURL = u
+	poller, err = netpoll.New(nil)
 	if err != nil {
 		clog.Fatal("server", "WebSocket", err)
 	}
@@ -138,32 +151,65 @@ func main() {
 		newCon := connect(i, u)
 		safeConn := Deadliner{newCon, ioTimeout}
 		driver := hub.Register(safeConn, i, getName(i))
-
-		desc := netpoll.Must(netpoll.HandleRead(newCon))
-
-		// On ajoute un listener sur la connection
-		poller.Start(desc, func(ev netpoll.Event) {
-			if ev&(netpoll.EventReadHup|netpoll.EventHup) != 0 {
-				clog.File("POLLR", "ERROR", "%v", ev)
-				// Connexion perdue ou terminée par le client
-				poller.Stop(desc)
-				hub.Remove(driver)
-				return
-			}
-			// Nouveau message entrant
-			pool.Schedule(func() {
-				clog.File("POLLR", driver.Name, "Msg IN")
-				if err := driver.Receive(); err != nil {
-					// Pb de reception, la connexion est rompue
-					clog.File("R-ERR", driver.Name, "%s", err)
-					poller.Stop(desc)
-					hub.Remove(driver)
-				}
-			})
-		})
+		listen(driver, newCon)
 
 		time.Sleep(time.Second)
 	}
 
 	<-exit
 }
+
+// scenarioAddress : Adresse imposée par le scénario (départ des drivers, prise en charge),
+// aléatoire sinon
+func scenarioAddress() datamodels.Address {
+	if conf.Bench.Scenario == scenarioHerd {
+		return herdAddress
+	}
+	return getNewAdress()
+}
+
+func main() {
+	confload.Load("config.ini", conf)
+
+	clog.LogLevel = 5
+	clog.StartLogging = true
+	if conf.FileLog != "" {
+		clog.EnableFileLog(conf.FileLog)
+	}
+	if conf.Auth.Token == "" {
+		conf.Auth.Token = defaultToken
+	}
+	if conf.Bench.Seed != 0 {
+		rand.Seed(conf.Bench.Seed)
+	}
+
+	loadAddresses()
+	loadZones()
+	loadDensity()
+	loadTraffic()
+	initClock()
+	loadTariff()
+	loadBehaviours()
+	loadGPSProfiles()
+	loadFleet()
+	if conf.Bench.Scenario == scenarioHerd {
+		herdAddress = getNewAdress()
+		clog.Info("main", "Scenario", "Thundering herd at %s", herdAddress.Name)
+	}
+
+	pool = gopool.NewPool(conf.Workers, conf.QueueSize, 10)
+	hub = NewHub(pool)
+
+	u := url.URL{Scheme: "ws", Host: conf.WSserver.Addr, Path: "/ws"}
+
+	switch conf.Bench.Mode {
+	case modeAuth:
+		os.Exit(runAuthSuite(u))
+	case modeConformance:
+		os.Exit(runConformance(u))
+	case modeLoad, "":
+		runLoad(u)
+	default:
+		clog.Fatal("main", "Mode", fmt.Errorf("unknown bench mode %q", conf.Bench.Mode))
+	}
+}
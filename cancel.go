@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+)
+
+// Annulations simulées
+const (
+	cancelBooker = "booker" // Le client annule avant la prise en charge
+	cancelDriver = "driver" // Le driver annule pendant l'approche
+	cancelNoShow = "noshow" // Le client ne se présente pas, le driver attend puis annule
+)
+
+var cancelKinds = []string{cancelBooker, cancelDriver, cancelNoShow}
+
+// cancelPlan : Annulation prévue par le driver pour la course qu'il vient d'accepter
+func cancelPlan() string {
+	n := rand.Intn(100)
+	if n < conf.RideConfig.DriverCancelPercent {
+		return cancelDriver
+	}
+	if n < conf.RideConfig.DriverCancelPercent+conf.RideConfig.NoShowPercent {
+		return cancelNoShow
+	}
+	return ""
+}
+
+// scheduleBookerCancel : Le booker annulera peut-être la course qu'il vient de créer
+func (d *Driver) scheduleBookerCancel(externalID string) {
+	if rand.Intn(100) >= conf.RideConfig.BookerCancelPercent {
+		return
+	}
+	delay := time.Duration(rand.Intn(conf.RideConfig.BookerCancelDelay+1)) * time.Second
+	time.AfterFunc(delay, func() {
+		// Envoyée par la boucle Life, seule à écrire les requêtes du driver
+		d.mu.Lock()
+		d.bookerCancels = append(d.bookerCancels, externalID)
+		d.mu.Unlock()
+	})
+}
+
+// takeBookerCancels : Annulations booker arrivées à échéance, à envoyer depuis Life
+func (d *Driver) takeBookerCancels() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := d.bookerCancels
+	d.bookerCancels = nil
+	return list
+}
+
+// cancelBooking : Annulation par le booker, impossible une fois le passager pris en charge
+func (d *Driver) cancelBooking(externalID string) {
+	id, driverID, pickedUp := d.hub.timelines.booking(externalID)
+	if id == 0 || pickedUp {
+		d.hub.cancels.skipped(externalID, id == 0)
+		return
+	}
+	clog.File("CANCEL", d.Name, "booker cancels ride %d", id)
+	d.hub.cancels.requested(externalID, cancelBooker, driverID)
+	d.hub.schedule.cancelled(externalID)
+	d.writeRequest("ChangeRideState", datamodels.ChangeRideState{ID: id, State: datamodels.Cancelled})
+}
+
+// cancelRide : Annulation par le driver de sa course en cours, le serveur doit répondre
+// par un PendingPaymentResponse portant le motif d'annulation
+func (d *Driver) cancelRide(kind string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.transition(datamodels.WaitACK, lifeTrigger) {
+		return
+	}
+	ext := d.hub.timelines.externalID(d.Ride)
+	d.cancelPlan = ""
+	d.cancelled = ext
+	d.requestedState = datamodels.Billing
+	d.hub.cancels.requested(ext, kind, d.ID)
+	if kind == cancelDriver {
+		d.hub.ledger.released(d, d.Ride.ID)
+	}
+	d.waitReq = d.updateRide(datamodels.Cancelled)
+}
+
+// leaveCancelledRide : Course annulée par le serveur, le driver redevient libre
+func (d *Driver) leaveCancelledRide() {
+	d.mu.Lock()
+	d.cancelled = d.hub.timelines.externalID(d.Ride)
+	d.cancelPlan = ""
+	d.Ride = datamodels.RideData{}
+	d.ToDest = 0
+	d.mu.Unlock()
+
+	d.requestChangeTaximeterStateReponse(datamodels.Free, lifeTrigger)
+}
+
+// cancelledRide : Suivi d'une course annulée
+type cancelledRide struct {
+	ExternalID   string
+	Kind         string
+	At           time.Time
+	DriverID     int // Driver en charge au moment de l'annulation, 0 si aucun
+	Reason       string
+	HasReason    bool // PendingPaymentResponse reçu avec la course annulée
+	Freed        bool // Driver revenu à Free après l'annulation
+	Redispatched int  // NewRide reçus après l'annulation
+}
+
+// expectRedispatch : Seule une annulation du driver laisse la course à servir
+func (c *cancelledRide) expectRedispatch() bool {
+	return c.Kind == cancelDriver
+}
+
+// Cancellations : Vérifie le traitement des annulations par le serveur
+type Cancellations struct {
+	mu        sync.Mutex
+	rides     map[string]*cancelledRide
+	order     []string
+	unknownID int // Annulations du booker impossibles faute d'ID serveur
+	tooLate   int // Annulations du booker renoncées après la prise en charge
+}
+
+// NewCancellations : Creation du suivi des annulations
+func NewCancellations() *Cancellations {
+	return &Cancellations{
+		rides: make(map[string]*cancelledRide),
+	}
+}
+
+// requested : Annulation envoyée au serveur
+func (c *Cancellations) requested(externalID, kind string, driverID int) {
+	c.mu.Lock()
+	if _, has := c.rides[externalID]; !has {
+		c.rides[externalID] = &cancelledRide{ExternalID: externalID, Kind: kind, At: time.Now(), DriverID: driverID}
+		c.order = append(c.order, externalID)
+	}
+	c.mu.Unlock()
+}
+
+// skipped : Annulation du booker abandonnée
+func (c *Cancellations) skipped(externalID string, unknownID bool) {
+	c.mu.Lock()
+	if unknownID {
+		c.unknownID++
+	} else {
+		c.tooLate++
+	}
+	c.mu.Unlock()
+}
+
+// reason : PendingPaymentResponse reçu pour une course annulée
+func (c *Cancellations) reason(externalID, reason string) {
+	c.mu.Lock()
+	if r, has := c.rides[externalID]; has {
+		r.HasReason = true
+		r.Reason = reason
+	}
+	c.mu.Unlock()
+}
+
+// freed : Le driver de la course annulée est revenu à Free
+func (c *Cancellations) freed(externalID string) {
+	c.mu.Lock()
+	if r, has := c.rides[externalID]; has {
+		r.Freed = true
+	}
+	c.mu.Unlock()
+}
+
+// notified : NewRide reçu, compte les redispatchs après annulation
+func (c *Cancellations) notified(externalID string, at time.Time) {
+	c.mu.Lock()
+	if r, has := c.rides[externalID]; has && at.After(r.At) {
+		r.Redispatched++
+	}
+	c.mu.Unlock()
+}
+
+// Report : Libération des drivers, redispatch et motifs d'annulation par type d'annulation
+func (c *Cancellations) Report(w io.Writer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reportTitle(w, "Ride cancellations")
+
+	byKind := make(map[string]int)
+	var notFreed, noReason, notRedispatched, redispatched []*cancelledRide
+	for _, ext := range c.order {
+		r := c.rides[ext]
+		byKind[r.Kind]++
+		if r.DriverID != 0 {
+			if !r.Freed {
+				notFreed = append(notFreed, r)
+			}
+			if !r.HasReason || r.Reason == "" {
+				noReason = append(noReason, r)
+			}
+		}
+		switch {
+		case r.expectRedispatch() && r.Redispatched == 0:
+			notRedispatched = append(notRedispatched, r)
+		case !r.expectRedispatch() && r.Redispatched > 0:
+			redispatched = append(redispatched, r)
+		}
+	}
+
+	fmt.Fprintf(w, "Cancellations: %d", len(c.order))
+	for _, k := range cancelKinds {
+		fmt.Fprintf(w, ", %s: %d", k, byKind[k])
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  Booker cancel skipped: %d without ride ID, %d after pickup\n", c.unknownID, c.tooLate)
+
+	c.list(w, "driver not freed", notFreed)
+	c.list(w, "missing CancellationReason", noReason)
+	c.list(w, "driver cancel not redispatched", notRedispatched)
+	c.list(w, "redispatched after final cancel", redispatched)
+
+	return len(notFreed) == 0 && len(noReason) == 0 && len(notRedispatched) == 0 && len(redispatched) == 0
+}
+
+func (c *Cancellations) list(w io.Writer, title string, rides []*cancelledRide) {
+	fmt.Fprintf(w, "%s %s: %d\n", passFail(len(rides) == 0), title, len(rides))
+	for i, r := range rides {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(rides)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %s %s, driver %d, reason %q, %d NewRide after cancel\n", r.ExternalID, r.Kind, r.DriverID, r.Reason, r.Redispatched)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"bench_dispatch/datamodels"
+)
+
+// maxBerlineSeats : Au delà, la course est réservée aux Vans
+const maxBerlineSeats = 4
+
+// weightedIndex : Tire un index selon les poids, 0 si aucun poids
+func weightedIndex(weights []int) int {
+	total := 0
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		return 0
+	}
+	n := rand.Intn(total)
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return 0
+}
+
+// rideLoad : Nb de passagers (à partir de 1) et de bagages (à partir de 0) d'une nouvelle course
+func rideLoad() (int, int) {
+	return weightedIndex(conf.RideConfig.PassengerWeights) + 1, weightedIndex(conf.RideConfig.LuggageWeights)
+}
+
+// canCarry : Le véhicule du driver a assez de places pour les passagers et les bagages
+func (d *Driver) canCarry(ride datamodels.RideData) bool {
+	return ride.NbPassengers <= d.Vehicle.NumberOfSeat && ride.NbLuggages <= d.Group.Luggages
+}
+
+// capacityRide : Chargement d'une course créée par le bench
+type capacityRide struct {
+	Passengers int
+	Luggages   int
+	VanOnly    bool
+	assigned   bool
+}
+
+// overload : Course proposée ou attribuée à un véhicule trop petit
+type overload struct {
+	ExternalID string
+	Passengers int
+	Luggages   int
+	VanOnly    bool
+	DriverID   int
+	Group      string
+	Type       datamodels.VehicleType
+	Seats      int
+	Trunk      int
+	TrunkOnly  bool // Seul le coffre est trop petit, limite inconnue du serveur
+}
+
+func (o overload) String() string {
+	return fmt.Sprintf("ride %s %d pax %d lug (van only %v) -> driver %d group %s: %s %d seats %d luggages",
+		o.ExternalID, o.Passengers, o.Luggages, o.VanOnly, o.DriverID, o.Group, vehicleTypeName(o.Type), o.Seats, o.Trunk)
+}
+
+// countTrunkOnly : Nb de dépassements dus au seul coffre
+func countTrunkOnly(list []overload) int {
+	n := 0
+	for _, o := range list {
+		if o.TrunkOnly {
+			n++
+		}
+	}
+	return n
+}
+
+// RideCapacity : Vérifie qu'une course n'est jamais attribuée à un véhicule trop petit
+type RideCapacity struct {
+	mu       sync.Mutex
+	rides    map[string]*capacityRide
+	offers   []overload
+	assigned []overload
+}
+
+// NewRideCapacity : Creation du contrôle de capacité
+func NewRideCapacity() *RideCapacity {
+	return &RideCapacity{
+		rides: make(map[string]*capacityRide),
+	}
+}
+
+// created : Enregistre le chargement de la course envoyée par un booker
+func (c *RideCapacity) created(create datamodels.CreateRide) {
+	c.mu.Lock()
+	c.rides[create.Ride.ExternalID] = &capacityRide{
+		Passengers: create.Ride.NbPassengers,
+		Luggages:   create.Ride.NbLuggages,
+		VanOnly:    create.SearchOptions.VehicleType == datamodels.Van,
+	}
+	c.mu.Unlock()
+}
+
+func (c *RideCapacity) overload(ext string, r *capacityRide, d *Driver) overload {
+	return overload{
+		ExternalID: ext,
+		Passengers: r.Passengers,
+		Luggages:   r.Luggages,
+		VanOnly:    r.VanOnly,
+		DriverID:   d.ID,
+		Group:      d.Group.Name,
+		Type:       d.VehicleType,
+		Seats:      d.Vehicle.NumberOfSeat,
+		Trunk:      d.Group.Luggages,
+		TrunkOnly:  r.seats(d),
+	}
+}
+
+// seats : Type de véhicule et places assises suffisants, les seules limites envoyées au serveur
+func (r *capacityRide) seats(d *Driver) bool {
+	if r.VanOnly && d.VehicleType != datamodels.Van {
+		return false
+	}
+	return r.Passengers <= d.Vehicle.NumberOfSeat
+}
+
+// fits : La course tient dans le véhicule, Vans obligatoires pour les grands groupes
+func (r *capacityRide) fits(d *Driver) bool {
+	if r.VanOnly && d.VehicleType != datamodels.Van {
+		return false
+	}
+	return d.canCarry(datamodels.RideData{NbPassengers: r.Passengers, NbLuggages: r.Luggages})
+}
+
+// offered : NewRide reçu par un driver
+func (c *RideCapacity) offered(d *Driver, ride datamodels.RideData) {
+	ext := d.hub.timelines.externalID(ride)
+
+	c.mu.Lock()
+	if r, has := c.rides[ext]; has && !r.fits(d) {
+		c.offers = append(c.offers, c.overload(ext, r, d))
+	}
+	c.mu.Unlock()
+}
+
+// attributed : Course attribuée au driver par le serveur
+func (c *RideCapacity) attributed(externalID string, d *Driver) {
+	c.mu.Lock()
+	if r, has := c.rides[externalID]; has && !r.assigned {
+		r.assigned = true
+		if !r.fits(d) {
+			c.assigned = append(c.assigned, c.overload(externalID, r, d))
+		}
+	}
+	c.mu.Unlock()
+}
+
+// Report : Répartition des chargements et courses attribuées à un véhicule trop petit
+func (c *RideCapacity) Report(w io.Writer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reportTitle(w, "Passenger and luggage capacity")
+
+	byPax := make(map[int]int)
+	byLug := make(map[int]int)
+	vanOnly, vanAssigned := 0, 0
+	for _, r := range c.rides {
+		byPax[r.Passengers]++
+		byLug[r.Luggages]++
+		if r.VanOnly {
+			vanOnly++
+			if r.assigned {
+				vanAssigned++
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Rides created: %d, van only: %d (%d assigned)\n", len(c.rides), vanOnly, vanAssigned)
+	fmt.Fprintf(w, "  Passengers %s\n", countLine(byPax))
+	fmt.Fprintf(w, "  Luggages   %s\n", countLine(byLug))
+
+	// Le coffre n'est pas transmis au serveur : ces dépassements sont informatifs
+	trunkOffers, trunkAssigned := countTrunkOnly(c.offers), countTrunkOnly(c.assigned)
+	seatAssigned := len(c.assigned) - trunkAssigned
+	fmt.Fprintf(w, "Over capacity offers: %d (%d trunk only)\n", len(c.offers), trunkOffers)
+	fmt.Fprintf(w, "Trunk too small, not known to the server: %d assignments\n", trunkAssigned)
+	fmt.Fprintf(w, "%s over seats or vehicle type assignments: %d\n", passFail(seatAssigned == 0), seatAssigned)
+	shown := 0
+	for _, o := range c.assigned {
+		if o.TrunkOnly {
+			continue
+		}
+		if shown == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", seatAssigned-shown)
+			break
+		}
+		fmt.Fprintf(w, "  %s\n", o)
+		shown++
+	}
+	return seatAssigned == 0
+}
+
+// countLine : Comptes triés par valeur, "1:12 2:5 ..."
+func countLine(m map[int]int) string {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	line := ""
+	for _, k := range keys {
+		line += fmt.Sprintf("%d:%d ", k, m[k])
+	}
+	return line
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/go-ini/ini"
 )
 
+// loaded : Fichier de conf chargé, pour la lecture des sections dynamiques
+var loaded *ini.File
+
 func tryingFile(confFile string) error {
 	if _, err := os.Stat(confFile); os.IsNotExist(err) {
 		clog.Output("Trying to load conf file %s ... err", confFile)
@@ -64,5 +67,26 @@ func Load(iniName string, data interface{}) error {
 	if err != nil {
 		return err
 	}
+	loaded = cfg
 	return nil
 }
+
+// Children : Noms des sections filles de parent, [parent.nom] donne nom
+func Children(parent string) []string {
+	if loaded == nil {
+		return nil
+	}
+	var names []string
+	for _, sec := range loaded.ChildSections(parent) {
+		names = append(names, strings.TrimPrefix(sec.Name(), parent+"."))
+	}
+	return names
+}
+
+// LoadSection : Initialise data avec la section [name]
+func LoadSection(name string, data interface{}) error {
+	if loaded == nil {
+		return errors.New("No conf file loaded")
+	}
+	return loaded.Section(name).MapTo(data)
+}
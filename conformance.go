@@ -0,0 +1,452 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/xid"
+)
+
+// unknownRideID : ID de course qui ne doit pas exister côté serveur
+const unknownRideID int64 = -1
+
+// probeTaximeterStates : Etats du taximètre connus du serveur
+var probeTaximeterStates = []datamodels.TaximeterState{
+	datamodels.Offline,
+	datamodels.Free,
+	datamodels.Occupied,
+	datamodels.Ghost,
+}
+
+// probeRideStates : Etats de course qu'un driver peut demander
+var probeRideStates = []datamodels.RideState{
+	datamodels.Approach,
+	datamodels.Delayed,
+	datamodels.Waiting,
+	datamodels.PickUpPassenger,
+	datamodels.PendingPayment,
+	datamodels.Ended,
+	datamodels.Cancelled,
+}
+
+// serverTaximeterTransitions : Transitions du taximètre que le serveur doit accepter.
+// Ghost n'est jamais accepté.
+var serverTaximeterTransitions = map[datamodels.TaximeterState][]datamodels.TaximeterState{
+	datamodels.Offline:  {datamodels.Free},
+	datamodels.Free:     {datamodels.Offline, datamodels.Occupied},
+	datamodels.Occupied: {datamodels.Free},
+}
+
+func serverTaximeterAllowed(from, to datamodels.TaximeterState) bool {
+	if to == datamodels.Ghost {
+		return false
+	}
+	if from == to {
+		return true
+	}
+	for _, s := range serverTaximeterTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// taximeterSetup : Suite d'états pour amener le serveur dans l'état voulu depuis Offline
+func taximeterSetup(state datamodels.TaximeterState) ([]datamodels.TaximeterState, bool) {
+	switch state {
+	case datamodels.Offline:
+		return nil, true
+	case datamodels.Free:
+		return []datamodels.TaximeterState{datamodels.Free}, true
+	case datamodels.Occupied:
+		return []datamodels.TaximeterState{datamodels.Free, datamodels.Occupied}, true
+	}
+	return nil, false
+}
+
+// rideSetup : Plus court chemin légal de Approach vers l'état voulu
+func rideSetup(state datamodels.RideState) ([]datamodels.RideState, bool) {
+	prev := map[datamodels.RideState]datamodels.RideState{datamodels.Approach: noRide}
+	queue := []datamodels.RideState{datamodels.Approach}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		if cur == state {
+			path := []datamodels.RideState{}
+			for s := cur; s != noRide; s = prev[s] {
+				path = append([]datamodels.RideState{s}, path...)
+			}
+			return path, true
+		}
+		for _, next := range rideTransitions[cur] {
+			if _, seen := prev[next]; !seen && next != noRide {
+				prev[next] = cur
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil, false
+}
+
+// probeOutcome : Résultat d'une sonde de transition
+type probeOutcome struct {
+	From     string
+	To       string
+	Expected bool // Transition censée être acceptée
+	Skipped  bool // Etat de départ inatteignable par une suite de transitions légales
+	Code     int
+	Err      error // Echec de mise en place ou absence de réponse
+}
+
+func (o probeOutcome) passed() bool {
+	if o.Skipped {
+		return true
+	}
+	if o.Err != nil {
+		return false
+	}
+	if o.Expected {
+		return o.Code == datamodels.ERR_SUCCESS.ID
+	}
+	return o.Code == datamodels.ERR_INVALID_STATE.ID || o.Code == datamodels.ERR_UNKNOW_RIDE.ID
+}
+
+func (o probeOutcome) cell() string {
+	exp := "R"
+	if o.Expected {
+		exp = "A"
+	}
+	switch {
+	case o.Skipped:
+		return "n/a"
+	case o.Err != nil:
+		return exp + "/-"
+	case o.Code == datamodels.ERR_SUCCESS.ID:
+		return exp + "/A"
+	case o.passed():
+		return exp + "/R"
+	}
+	return fmt.Sprintf("%s/%d", exp, o.Code)
+}
+
+// Conformance : Matrices de conformité des transitions du serveur
+type Conformance struct {
+	u         url.URL
+	nextID    int
+	taximeter []probeOutcome
+	ride      []probeOutcome
+	unknown   probeOutcome
+}
+
+func (c *Conformance) dial() (*probeConn, error) {
+	p, err := dialProbe(c.u)
+	if err != nil {
+		return nil, err
+	}
+	c.nextID++
+	if err := p.login(conf.Bench.NbDrivers+c.nextID, fmt.Sprintf("bench-probe-%d", c.nextID)); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// login : Authentifie la connexion de test
+func (p *probeConn) login(id int, name string) error {
+	login := datamodels.Login{
+		ID:    id,
+		Name:  name,
+		State: datamodels.Offline,
+		Token: conf.Auth.Token,
+	}
+	resp, err := p.call("Login", login, probeTimeout(), "LoginResponse")
+	if err != nil {
+		return err
+	}
+	return statusError(resp)
+}
+
+func (p *probeConn) setTaximeter(state datamodels.TaximeterState) (*datamodels.Response, error) {
+	return p.call("ChangeTaximeterState", datamodels.ChangeTaximeterState{State: state}, probeTimeout(), "ChangeTaximeterStateReponse")
+}
+
+func (p *probeConn) setRide(id int64, state datamodels.RideState) (*datamodels.Response, error) {
+	return p.call("ChangeRideState", datamodels.ChangeRideState{ID: id, State: state}, probeTimeout(), "ChangeRideStateResponse")
+}
+
+func statusError(resp *datamodels.Response) error {
+	if resp.Status.ID != datamodels.ERR_SUCCESS.ID {
+		return fmt.Errorf("%s [%d] %s", resp.Method, resp.Status.ID, resp.Status.Message)
+	}
+	return nil
+}
+
+func (c *Conformance) probeTaximeter(from, to datamodels.TaximeterState) probeOutcome {
+	out := probeOutcome{From: taximeterName(from), To: taximeterName(to), Expected: serverTaximeterAllowed(from, to)}
+
+	setup, ok := taximeterSetup(from)
+	if !ok {
+		out.Skipped = true
+		return out
+	}
+
+	p, err := c.dial()
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	defer p.close()
+
+	for _, s := range setup {
+		resp, err := p.setTaximeter(s)
+		if err == nil {
+			err = statusError(resp)
+		}
+		if err != nil {
+			out.Err = fmt.Errorf("setup %s: %s", taximeterName(s), err)
+			return out
+		}
+	}
+
+	resp, err := p.setTaximeter(to)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	out.Code = resp.Status.ID
+	return out
+}
+
+// acceptProbeRide : Crée une course depuis booker et la fait accepter par driver
+func (c *Conformance) acceptProbeRide(driver, booker *probeConn) (int64, error) {
+	from := getNewAdress()
+
+	resp, err := driver.setTaximeter(datamodels.Free)
+	if err == nil {
+		err = statusError(resp)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := driver.send("UpdateDriverLocation", datamodels.UpdateDriverLocation{
+		Coord:          from.Coord,
+		VehicleOptions: []datamodels.VehicleOption{datamodels.CovidShield},
+		VehicleType:    datamodels.Berline,
+	}); err != nil {
+		return 0, err
+	}
+
+	externalID := xid.New().String()
+	if _, err := booker.send("CreateRide", datamodels.CreateRide{
+		Ride: datamodels.RideData{
+			ExternalID:  externalID,
+			Origin:      datamodels.Defaut,
+			StartDate:   datamodels.FormatDateForIOS(time.Now()),
+			State:       datamodels.Pending,
+			IsImmediate: true,
+			FromAddress: from,
+			ToAddress:   getNewAdress(),
+		},
+		SearchOptions: datamodels.SearchOptions{VehicleType: datamodels.Berline},
+	}); err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(probeTimeout())
+	for {
+		msg, err := driver.expect(-1, time.Until(deadline), "NewRide")
+		if err != nil {
+			return 0, fmt.Errorf("NewRide: %s", err)
+		}
+		var newRide datamodels.CreateRide
+		mapstructure.Decode(msg.Params, &newRide)
+		if newRide.Ride.ExternalID != externalID {
+			continue
+		}
+
+		resp, err := driver.call("AcceptRide", datamodels.AcceptRide{ID: newRide.Ride.ID}, probeTimeout(), "AcceptRideResponse")
+		if err == nil {
+			err = statusError(resp)
+		}
+		return newRide.Ride.ID, err
+	}
+}
+
+func (c *Conformance) probeRide(from, to datamodels.RideState) probeOutcome {
+	out := probeOutcome{From: rideName(from), To: rideName(to), Expected: rideAllowed(from, to)}
+
+	setup, ok := rideSetup(from)
+	if !ok {
+		out.Skipped = true
+		return out
+	}
+
+	driver, err := c.dial()
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	defer driver.close()
+	booker, err := c.dial()
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	defer booker.close()
+
+	rideID, err := c.acceptProbeRide(driver, booker)
+	if err != nil {
+		out.Err = fmt.Errorf("setup ride: %s", err)
+		return out
+	}
+
+	for _, s := range setup {
+		resp, err := driver.setRide(rideID, s)
+		if err == nil {
+			err = statusError(resp)
+		}
+		if err != nil {
+			out.Err = fmt.Errorf("setup %s: %s", rideName(s), err)
+			return out
+		}
+	}
+
+	resp, err := driver.setRide(rideID, to)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	out.Code = resp.Status.ID
+	return out
+}
+
+func (c *Conformance) probeUnknownRide() probeOutcome {
+	out := probeOutcome{From: "Unknown ride", To: rideName(datamodels.Approach)}
+
+	p, err := c.dial()
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	defer p.close()
+
+	resp, err := p.setRide(unknownRideID, datamodels.Approach)
+	if err != nil {
+		out.Err = err
+		return out
+	}
+	out.Code = resp.Status.ID
+	if out.Code != datamodels.ERR_UNKNOW_RIDE.ID {
+		out.Err = fmt.Errorf("expected [%d] %s, got [%d] %s", datamodels.ERR_UNKNOW_RIDE.ID, datamodels.ERR_UNKNOW_RIDE.Message, resp.Status.ID, resp.Status.Message)
+	}
+	return out
+}
+
+// runConformance : Sonde toutes les transitions et retourne le code de sortie
+func runConformance(u url.URL) int {
+	c := &Conformance{u: u}
+	addReporter(c)
+
+	for _, from := range probeTaximeterStates {
+		for _, to := range probeTaximeterStates {
+			out := c.probeTaximeter(from, to)
+			clog.Trace("conformance", "Taximeter", "%s -> %s : %s", out.From, out.To, out.cell())
+			c.taximeter = append(c.taximeter, out)
+		}
+	}
+	for _, from := range probeRideStates {
+		for _, to := range probeRideStates {
+			out := c.probeRide(from, to)
+			clog.Trace("conformance", "Ride", "%s -> %s : %s", out.From, out.To, out.cell())
+			c.ride = append(c.ride, out)
+		}
+	}
+	c.unknown = c.probeUnknownRide()
+
+	return writeReport()
+}
+
+// writeMatrix : Ecrit une matrice de transitions et retourne le nombre de sondes réussies
+func writeMatrix(w io.Writer, title string, states []string, outcomes []probeOutcome) int {
+	passed := 0
+
+	fmt.Fprintf(w, "%s (rows: from, columns: to, cell: expected/actual)\n", title)
+	fmt.Fprintf(w, "%-16s", "")
+	for _, s := range states {
+		fmt.Fprintf(w, " %-16s", s)
+	}
+	fmt.Fprintln(w)
+	for i, from := range states {
+		fmt.Fprintf(w, "%-16s", from)
+		for j := range states {
+			out := outcomes[i*len(states)+j]
+			mark := " "
+			switch {
+			case out.Skipped:
+			case out.passed():
+				passed++
+			default:
+				mark = "!"
+			}
+			fmt.Fprintf(w, " %-16s", out.cell()+mark)
+		}
+		fmt.Fprintln(w)
+	}
+	return passed
+}
+
+// Report : Matrices attendu / obtenu et détail des écarts
+func (c *Conformance) Report(w io.Writer) bool {
+	reportTitle(w, "Server transition conformance")
+
+	taxiStates := make([]string, len(probeTaximeterStates))
+	for i, s := range probeTaximeterStates {
+		taxiStates[i] = taximeterName(s)
+	}
+	rideStates := make([]string, len(probeRideStates))
+	for i, s := range probeRideStates {
+		rideStates[i] = rideName(s)
+	}
+
+	passed := writeMatrix(w, "ChangeTaximeterState", taxiStates, c.taximeter)
+	fmt.Fprintln(w)
+	passed += writeMatrix(w, "ChangeRideState", rideStates, c.ride)
+	fmt.Fprintln(w)
+
+	total := 1
+	for _, out := range append(append([]probeOutcome{}, c.taximeter...), c.ride...) {
+		if !out.Skipped {
+			total++
+		}
+	}
+	if c.unknown.passed() {
+		passed++
+	}
+	fmt.Fprintf(w, "%s Unknown ride -> %s\n", passFail(c.unknown.passed()), c.unknown.cell())
+
+	for _, out := range append(append([]probeOutcome{}, c.taximeter...), c.ride...) {
+		if out.passed() {
+			continue
+		}
+		if out.Err != nil {
+			fmt.Fprintf(w, "  %s -> %s : %s\n", out.From, out.To, out.Err)
+		} else {
+			e := datamodels.ErrorFromCode(out.Code)
+			fmt.Fprintf(w, "  %s -> %s : got [%d] %s\n", out.From, out.To, e.ID, e.Message)
+		}
+	}
+	if c.unknown.Err != nil {
+		fmt.Fprintf(w, "  Unknown ride : %s\n", c.unknown.Err)
+	}
+
+	fmt.Fprintf(w, "Conformance: %d/%d transitions (%.1f%%)\n", passed, total, 100*float64(passed)/float64(total))
+	return passed == total
+}
@@ -14,13 +14,17 @@ type Globals struct {
 // Bench : Parametre des tests
 type Bench struct {
 	NbDrivers      int
-	BaseTimer      int  // Basde de temps
-	SendPos        int  // Nb de base de temps entre deux envois de position
-	PingDelay      int  // Nb de base de temps entre deux envois de ping
-	IdleDuration   int  // Durée de la pause en BT
-	IdleCreateRide bool // Doit on generer des courses
-	PercentForIdle int  // Pourcentage de chance de passer en Idle
-	KmByBT         int  // Nb de Km parcourus par BT
+	BaseTimer      int     // Basde de temps
+	SendPos        int     // Nb de base de temps entre deux envois de position
+	PingDelay      int     // Nb de base de temps entre deux envois de ping
+	IdleDuration   int     // Durée de la pause en BT
+	IdleCreateRide bool    // Doit on generer des courses
+	PercentForIdle int     // Pourcentage de chance de passer en Idle
+	KmByBT         float64 // Nb de Km parcourus par BT sans modèle de trafic
+	Mode           string  // Mode du bench : load (defaut), auth ou conformance
+	ProbeTimeout   int     // Attente max d'une réponse en secondes dans les modes de test
+	Scenario       string  // Scénario de charge : vide ou herd (tous au même point)
+	Seed           int64   // Graine du générateur aléatoire, 0 pour une graine par défaut
 }
 
 // WSserver : Configuration des servers
@@ -31,6 +35,154 @@ type WSserver struct {
 // RideConfig : paramètres d'une course
 type RideConfig struct {
 	TimeBeetwinSteps int
+	PassengerWeights []int // Poids des courses à 1, 2, 3... passagers
+	LuggageWeights   []int // Poids des courses à 0, 1, 2... bagages
+
+	ScheduledPercent  int // Part des courses réservées à l'avance
+	ScheduleMinDelay  int // Délai mini en secondes entre la réservation et le départ
+	ScheduleMaxDelay  int // Délai maxi en secondes entre la réservation et le départ
+	DispatchWindow    int // Secondes avant le départ où le serveur doit envoyer le NewRide
+	DispatchTolerance int // Marge en secondes autour de la fenêtre de dispatch
+
+	BookerCancelPercent int // Part des courses annulées par le client avant la prise en charge
+	BookerCancelDelay   int // Délai maxi en secondes entre la création et l'annulation
+	DriverCancelPercent int // Part des courses annulées par le driver pendant l'approche
+	NoShowPercent       int // Part des courses où le client ne se présente pas
+	NoShowWait          int // Nb de BT d'attente du driver avant d'annuler
+}
+
+// Auth : Jetons utilisés pour l'authentification des drivers
+type Auth struct {
+	Token            string // Jeton JWT valide des drivers
+	UnknownUserToken string // Jeton valide d'un utilisateur inconnu du serveur
+}
+
+// LoginPolicy : Politique de reconnexion après un login refusé
+type LoginPolicy struct {
+	MaxRetries int // Nb de tentatives après le premier échec
+	RetryDelay int // Attente en BT avant la première nouvelle tentative
+	Backoff    int // Multiplicateur de l'attente entre deux tentatives
+	Timeout    int // Nb de BT sans LoginResponse avant de compter un échec, 0 pour désactiver
+}
+
+// Watchdog : Détection des drivers bloqués en attente d'une réponse du serveur
+type Watchdog struct {
+	WaitACKTimeout int    // Nb de BT max en WaitACK, 0 pour désactiver
+	WaitOKTimeout  int    // Nb de BT max en WaitOK, 0 pour désactiver
+	Recover        string // none, resend ou reset (retour à Free, signalé au serveur)
+	MaxResend      int    // Nb de renvois avant de passer à Free en mode resend
+}
+
+// Thresholds : Seuils d'échec du bench
+type Thresholds struct {
+	MaxStuck    int     // Nb max de drivers distincts bloqués détectés par le watchdog
+	MaxKmDrift  float64 // Ecart max en % entre les km du serveur et ceux envoyés, 0 pour ne pas vérifier
+	MaxMinDrift float64 // Ecart max en % entre les minutes du serveur et celles simulées, 0 pour ne pas vérifier
+}
+
+// Dispatch : Politique de dispatch attendue du serveur
+type Dispatch struct {
+	Radius    float64 // Distance max en mètres d'un driver notifié, 0 pour ne pas vérifier
+	MaxFanOut int     // Nb max de drivers notifiés par course, 0 pour ne pas vérifier
+
+	OfferValidity int // Validité en secondes d'un NewRide sans validUntil, 0 si inconnue
+}
+
+// DriverGroup : Groupe de drivers partageant le même véhicule, section [Group.nom]
+type DriverGroup struct {
+	Count          int      // Nb de drivers du groupe
+	VehicleType    string   // Berline, Green, Medical, Other, Prestige ou Van
+	VehicleOptions []string // CPAM, CovidShield, EnglishSpoken, Mkids1..4, Pets, Access
+	Brand          string
+	Model          string
+	Color          string
+	Plate          string // Préfixe des immatriculations, complété par l'ID du driver
+	Seats          int
+	Luggages       int    // Capacité du coffre, non transmise au serveur
+	Profile        string // Profil de comportement [Profile.nom], vide pour tout accepter
+	ShiftStart     string // Début de service HH:MM en heure de simulation, vide pour toujours en service
+	ShiftEnd       string // Fin de service HH:MM, avant le début pour un service de nuit
+	BreakEvery     int    // Minutes de service entre deux pauses, 0 sans pause
+	BreakDuration  int    // Durée d'une pause en minutes
+	GPS            string // Profil de capteur GPS [GPS.nom], vide pour des positions exactes
+	Zone           string // Zone de travail du groupe, vide pour toute la ville
+}
+
+// AddressSource : Jeu d'adresses, section [Addresses.nom]
+type AddressSource struct {
+	Format     string // simple, ban, geojson ou csv
+	File       string
+	Scenario   string // Jeu chargé pour ce scénario seulement, vide pour tous
+	Separator  string // CSV : séparateur de colonnes, ; par défaut
+	Header     bool   // csv : la première ligne est un en-tête
+	NameColumn string // Colonnes du nom jointes par un espace (par nom d'en-tête ou numéro), propriétés en geojson
+	LatColumn  string
+	LngColumn  string
+}
+
+// Density : Grille de densité de population pour les prises en charge et destinations
+type Density struct {
+	File     string // Raster CSV longitude;latitude;poids ou GeoJSON de polygones pondérés (weight)
+	Format   string // csv ou geojson, selon l'extension si vide
+	CellSize int    // csv : côté des cellules en mètres
+	MaxSnap  int    // Distance maxi en mètres du point tiré à l'adresse retenue, 0 sans limite
+}
+
+// Traffic : Modèle de vitesse selon l'heure de simulation, la zone et la classe de voie
+type Traffic struct {
+	CentreSpeed      float64   // km/h dans les zones du centre, CitySpeed si 0
+	CitySpeed        float64   // km/h en ville, 0 pour garder KmByBT
+	ArterialSpeed    float64   // km/h sur voie rapide, 0 sans voie rapide
+	ArterialKm       float64   // Km du départ et de l'arrivée au-delà desquels on roule sur voie rapide
+	CentreZones      []string  // Zones [Zones] du centre-ville
+	Congestion       []float64 // Facteur de vitesse par heure (24 valeurs), hors centre
+	CentreCongestion []float64 // Facteur de vitesse par heure (24 valeurs), dans le centre
+}
+
+// Zones : Zones géographiques GeoJSON
+type Zones struct {
+	File                string // FeatureCollection de Polygon / MultiPolygon, propriétés name, kind, demand
+	OutOfServicePercent int    // Part des courses créées dans une zone out_of_service
+}
+
+// Behaviour : Comportement des drivers face à un NewRide, section [Profile.nom]
+type Behaviour struct {
+	AcceptPercent     int    // Part des offres lues acceptées
+	ThinkMin          int    // Temps de réaction mini en ms
+	ThinkMax          int    // Temps de réaction maxi en ms
+	ThinkDistribution string // uniform, exponential ou normal
+	IgnorePercent     int    // Part des offres jamais lues
+	LatePercent       int    // Part des offres acceptées après expiration
+	LateDelay         int    // Retard en ms après l'expiration de l'offre
+}
+
+// Clock : Horloge de simulation pilotant les services des drivers
+type Clock struct {
+	Start string  // Heure de simulation HH:MM au lancement, heure courante si vide
+	Speed float64 // Minutes simulées par minute réelle
+}
+
+// Tariff : Tarif appliqué par le serveur, pour vérifier les montants facturés
+type Tariff struct {
+	PickUpFee      float64 // Prise en charge HT
+	PerKm          float64 // Prix HT du km
+	PerMin         float64 // Prix HT de la minute
+	NightSurcharge float64 // Majoration de nuit en %
+	NightStart     string  // Début du tarif de nuit HH:MM
+	NightEnd       string  // Fin du tarif de nuit HH:MM
+	VAT            float64 // Taux de TVA en %
+	Tolerance      float64 // Ecart accepté en % du montant attendu
+}
+
+// Report : Rapport de fin de bench
+type Report struct {
+	File string // Fichier du rapport, sortie standard si vide
+}
+
+// Export : Export des trajectoires en fin de bench
+type Export struct {
+	Dir     string   // Répertoire d'export, vide pour ne rien enregistrer
+	Formats []string // gpx, kml, geojson ; tous si vide
 }
 
 // ConfigData : Data structure du fichier de conf
@@ -39,4 +191,16 @@ type ConfigData struct {
 	Bench
 	WSserver
 	RideConfig
+	Auth
+	LoginPolicy
+	Watchdog
+	Thresholds
+	Dispatch
+	Clock
+	Tariff
+	Zones
+	Density
+	Traffic
+	Export
+	Report
 }
@@ -12,3 +12,27 @@ var (
 	ERR_EMPTY_REQUEST      = Error{ID: 90, Message: "Empty or malformed request"}
 	ERR_NOT_IMPLEMENTED    = Error{ID: 99, Message: "Function Not implemented"}
 )
+
+// Errors : Liste des erreurs connues du protocole
+var Errors = []Error{
+	ERR_SUCCESS,
+	ERR_NOT_LOGGED,
+	ERR_UNKNOWN_USER,
+	ERR_BAD_TOKEN,
+	ERR_UNREADABLE_TOKEN,
+	ERR_INVALID_STATE,
+	ERR_UNKNOW_RIDE,
+	ERR_RIDE_NOT_AVAILABLE,
+	ERR_EMPTY_REQUEST,
+	ERR_NOT_IMPLEMENTED,
+}
+
+// ErrorFromCode : Retrouve l'erreur du protocole correspondant à un code
+func ErrorFromCode(code int) Error {
+	for _, e := range Errors {
+		if e.ID == code {
+			return e
+		}
+	}
+	return Error{ID: code, Message: "Unknown error code"}
+}
@@ -77,12 +77,19 @@ type MonitorConfig struct {
 	Config Globals `json:"config"`
 }
 
+// LoginResponse : Identité du driver retournée par le serveur
+type LoginResponse struct {
+	ID   int    `mapstructure:"id" json:"id"`
+	Name string `mapstructure:"name" json:"name"`
+}
+
 // Payment : Payement d'une course
 type PendingPaymentResponse struct {
-	Ride               RideData `mapstructure:"ride" json:"ride"`
-	PickUpAddress      Address  `mapstructure:"pickUpAddress" json:"pickUpAddress"`
-	Payment            Payment  `mapstructure:"payment" json:"payment"`
-	CancellationReason string   `mapstructure:"cancellationReason" json:"cancellationReason"`
+	Ride               RideData     `mapstructure:"ride" json:"ride"`
+	PickUpAddress      Address      `mapstructure:"pickUpAddress" json:"pickUpAddress"`
+	Payment            Payment      `mapstructure:"payment" json:"payment"`
+	Stats              [3]RideStats `mapstructure:"stats" json:"stats"` // Hors payment dans l'exemple du protocole
+	CancellationReason string       `mapstructure:"cancellationReason" json:"cancellationReason"`
 }
 
 // AcceptRideResponse : Retour pour course acceptée
@@ -23,6 +23,16 @@ const (
 	Van
 )
 
+// VehicleTypes : Types de véhicule par nom
+var VehicleTypes = map[string]VehicleType{
+	"Berline":  Berline,
+	"Green":    Green,
+	"Medical":  Medical,
+	"Other":    Other,
+	"Prestige": Prestige,
+	"Van":      Van,
+}
+
 // VehicleOption : Options list
 type VehicleOption uint16
 
@@ -39,6 +49,19 @@ const (
 	Access
 )
 
+// VehicleOptions : Options de véhicule par nom
+var VehicleOptions = map[string]VehicleOption{
+	"CPAM":          CPAM,
+	"CovidShield":   CovidShield,
+	"EnglishSpoken": EnglishSpoken,
+	"Mkids1":        Mkids1,
+	"Mkids2":        Mkids2,
+	"Mkids3":        Mkids3,
+	"Mkids4":        Mkids4,
+	"Pets":          Pets,
+	"Access":        Access,
+}
+
 // Vehicle : Descriptif du véhicule du Driver
 type Vehicle struct {
 	ID           int         `mapstructure:"id" json:"id"`
@@ -142,6 +165,7 @@ type RideData struct {
 	Memo         string       `mapstructure:"memo" json:"memo"`
 	Reference    string       `mapstructure:"reference" json:"reference"`
 	StartDate    string       `mapstructure:"startDate" json:"startDate"`
+	ValidUntil   string       `mapstructure:"validUntil" json:"validUntil,omitempty"`
 	State        RideState    `mapstructure:"state" json:"state"`
 	ToAddress    Address      `mapstructure:"toAddress" json:"toAddress"`
 	IsImmediate  bool         `mapstructure:"isImmediate" json:"isImmediate"`
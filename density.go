@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// addressIndexCell : Taille en mètres des cellules de l'index des adresses
+const addressIndexCell = 250
+
+var (
+	density      *geoloc.Density
+	addressIndex *geoloc.Index
+)
+
+// loadDensity : Charge la grille de densité et indexe les adresses pour le recalage
+func loadDensity() {
+	c := conf.Density
+	if c.File == "" {
+		return
+	}
+
+	var err error
+	switch {
+	case c.Format == "geojson" || c.Format == "" && strings.HasSuffix(c.File, "json"):
+		density, err = geoloc.LoadDensityGeoJSON(c.File)
+	case c.Format == "csv" || c.Format == "":
+		cell := c.CellSize
+		if cell <= 0 {
+			cell = 200
+		}
+		density, err = geoloc.LoadDensityCSV(c.File, float64(cell))
+	default:
+		err = fmt.Errorf("unknown density format %q", c.Format)
+	}
+	if err != nil {
+		clog.Fatal("main", "Density", err)
+	}
+
+	addressIndex = geoloc.NewIndex(addressIndexCell)
+	for i, a := range address {
+		addressIndex.Insert(i, a.Coord.Latitude, a.Coord.Longitude)
+	}
+	clog.Info("main", "Density", "%d weighted cells from %s, %d addresses indexed", density.Len(), c.File, addressIndex.Len())
+}
+
+// maxSnapTries : Nb de tirages pour trouver une adresse à moins de MaxSnap mètres
+const maxSnapTries = 10
+
+// densityAddress : Point tiré selon la densité puis recalé sur l'adresse connue la plus proche.
+// Un point trop loin de toute adresse (mer, zone vide du jeu) est retiré.
+func densityAddress() datamodels.Address {
+	best, bestMeters := -1, 0.0
+	for try := 0; try < maxSnapTries; try++ {
+		lat, lng := density.Sample()
+		id, meters, ok := addressIndex.Nearest(lat, lng)
+		if !ok {
+			break
+		}
+		if best < 0 || meters < bestMeters {
+			best, bestMeters = id, meters
+		}
+		if conf.Density.MaxSnap <= 0 || meters <= float64(conf.Density.MaxSnap) {
+			break
+		}
+	}
+	if best < 0 {
+		return getNewAdress()
+	}
+	return address[best]
+}
@@ -23,8 +23,7 @@ func output() {
 		case termbox.EventKey:
 			if ev.Ch == 'q' {
 				termbox.Close()
-				hub.disconnectAll()
-				os.Exit(0)
+				shutdown()
 			}
 		}
 	}()
@@ -42,6 +41,7 @@ func displayHub() {
 	hub.mu.RUnlock()
 
 	var i int
+	tbprintf(1, 0, termbox.ColorDefault, termbox.ColorDefault, "Sim %s", simNow().Format("15:04:05"))
 	termbox.SetCursor(1, 1)
 	for i = 1; i <= nbDrivers; i++ {
 		hub.mu.RLock()
@@ -69,6 +69,11 @@ func displayHub() {
 			tbprintf(15, i, termbox.ColorRed, termbox.ColorBlack, "Wait ACK")
 		default:
 		}
+		if zeDriver.stuck {
+			tbprintf(23, i, termbox.ColorRed, termbox.ColorDefault, "!")
+		} else {
+			tbprintf(23, i, termbox.ColorDefault, termbox.ColorDefault, " ")
+		}
 		tbprintf(26, i, termbox.ColorDefault, termbox.ColorDefault, "%f %f", zeDriver.Coord.Latitude, zeDriver.Coord.Longitude)
 		tbprintf(46, i, termbox.ColorDefault, termbox.ColorDefault, "%.1f Km ", zeDriver.ToDest)
 		if zeDriver.Ride.ToAddress.Name == "" {
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// fanoutNotice : Réception d'un NewRide par un driver
+type fanoutNotice struct {
+	DriverID int
+	Name     string
+	Distance float64 // Distance en mètres entre la dernière position envoyée et la prise en charge, -1 si aucune
+	State    datamodels.TaximeterState
+	At       time.Time
+	Latency  time.Duration // Depuis l'envoi du CreateRide, -1 si inconnu
+}
+
+// fanoutRide : Drivers notifiés pour une course
+type fanoutRide struct {
+	ID      int64
+	Pickup  datamodels.Coordinates
+	notices []fanoutNotice
+}
+
+// inversions : Nb de paires notifiées dans le désordre des distances, dans l'ordre de réception
+func (r *fanoutRide) inversions() (int, int) {
+	sort.SliceStable(r.notices, func(i, j int) bool { return r.notices[i].At.Before(r.notices[j].At) })
+
+	inv, pairs := 0, 0
+	for i := range r.notices {
+		if r.notices[i].Distance < 0 {
+			continue
+		}
+		for j := i + 1; j < len(r.notices); j++ {
+			if r.notices[j].Distance < 0 {
+				continue
+			}
+			pairs++
+			if r.notices[j].Distance < r.notices[i].Distance {
+				inv++
+			}
+		}
+	}
+	return inv, pairs
+}
+
+// FanOut : Qui a reçu quelle course, quand et à quelle distance
+type FanOut struct {
+	mu    sync.Mutex
+	rides map[int64]*fanoutRide
+	order []int64
+}
+
+// NewFanOut : Creation du suivi des notifications NewRide
+func NewFanOut() *FanOut {
+	return &FanOut{
+		rides: make(map[int64]*fanoutRide),
+	}
+}
+
+// notified : Enregistre la réception d'un NewRide par le driver
+func (f *FanOut) notified(d *Driver, ride datamodels.RideData, at time.Time) {
+	// Le serveur ne connaît que la dernière position envoyée, bruit GPS compris
+	d.mu.RLock()
+	coord := d.LastSent
+	state := d.DriverState
+	d.mu.RUnlock()
+
+	notice := fanoutNotice{
+		DriverID: d.ID,
+		Name:     d.Name,
+		Distance: -1,
+		State:    state,
+		At:       at,
+		Latency:  -1,
+	}
+	if coord.Latitude != 0 || coord.Longitude != 0 {
+		notice.Distance = geoloc.DistanceAccurate(coord.Latitude, coord.Longitude, ride.FromAddress.Coord.Latitude, ride.FromAddress.Coord.Longitude)
+	}
+	if created, ok := d.hub.timelines.createdAt(ride); ok {
+		notice.Latency = at.Sub(created)
+	}
+
+	f.mu.Lock()
+	r, has := f.rides[ride.ID]
+	if !has {
+		r = &fanoutRide{ID: ride.ID, Pickup: ride.FromAddress.Coord}
+		f.rides[ride.ID] = r
+		f.order = append(f.order, ride.ID)
+	}
+	r.notices = append(r.notices, notice)
+	f.mu.Unlock()
+}
+
+// Report : Taille du fan-out, rayon effectif, états notifiés et ordre des notifications
+func (f *FanOut) Report(w io.Writer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reportTitle(w, "NewRide fan-out")
+
+	var sizes, dists samples
+	var latencies durations
+	byState := make(map[datamodels.TaximeterState]int)
+	inv, pairs := 0, 0
+	beyond, tooWide, unlocated := 0, 0, 0
+
+	for _, id := range f.order {
+		r := f.rides[id]
+		sizes = append(sizes, float64(len(r.notices)))
+		if conf.Dispatch.MaxFanOut > 0 && len(r.notices) > conf.Dispatch.MaxFanOut {
+			tooWide++
+		}
+		for _, n := range r.notices {
+			byState[n.State]++
+			if n.Latency >= 0 {
+				latencies = append(latencies, n.Latency)
+			}
+			if n.Distance < 0 {
+				unlocated++
+				continue
+			}
+			dists = append(dists, n.Distance)
+			if conf.Dispatch.Radius > 0 && n.Distance > conf.Dispatch.Radius {
+				beyond++
+			}
+		}
+		i, p := r.inversions()
+		inv += i
+		pairs += p
+	}
+
+	fmt.Fprintf(w, "Rides notified: %d\n", len(f.order))
+	fmt.Fprintf(w, "  Drivers per ride     %s\n", sizes.summary(""))
+	fmt.Fprintf(w, "  Distance to pickup   %s\n", dists.summary("m"))
+	fmt.Fprintf(w, "  Notification latency %s\n", latencies.summary())
+	if unlocated > 0 {
+		fmt.Fprintf(w, "  Notified before any position sent: %d\n", unlocated)
+	}
+	if pairs > 0 {
+		fmt.Fprintf(w, "  Nearest-first order  %.1f%% of driver pairs\n", 100*float64(pairs-inv)/float64(pairs))
+	}
+
+	states := make([]datamodels.TaximeterState, 0, len(byState))
+	for s := range byState {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+	for _, s := range states {
+		fmt.Fprintf(w, "  Notified while %-8s : %d\n", taximeterName(s), byState[s])
+	}
+
+	ok := true
+	if conf.Dispatch.Radius > 0 {
+		ok = ok && beyond == 0
+		fmt.Fprintf(w, "%s notified beyond %.0fm: %d\n", passFail(beyond == 0), conf.Dispatch.Radius, beyond)
+	}
+	if conf.Dispatch.MaxFanOut > 0 {
+		ok = ok && tooWide == 0
+		fmt.Fprintf(w, "%s rides offered to more than %d drivers: %d\n", passFail(tooWide == 0), conf.Dispatch.MaxFanOut, tooWide)
+	}
+	return ok
+}
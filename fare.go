@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+)
+
+// Types des statistiques de PendingPaymentResponse (champ type)
+const (
+	statAmount = iota
+	statKm
+	statMin
+)
+
+// rideMeter : Distance et durée de la course simulées par le driver
+type rideMeter struct {
+	Km         float64 // Distance de la prise en charge à la destination
+	Sent       float64 // Distance entre les positions envoyées au serveur
+	PickedUp   time.Time
+	DroppedOff time.Time
+	last       datamodels.Coordinates
+}
+
+func (m rideMeter) minutes() float64 {
+	return m.DroppedOff.Sub(m.PickedUp).Minutes()
+}
+
+// Tarif de nuit, en minutes depuis minuit
+var (
+	nightStart, nightEnd int
+	nightRate            bool
+)
+
+// loadTariff : Vérifie la conf du tarif de nuit
+func loadTariff() {
+	t := conf.Tariff
+	if t.NightStart == "" || t.NightEnd == "" {
+		return
+	}
+	var err error
+	if nightStart, err = parseShiftTime(t.NightStart); err != nil {
+		clog.Fatal("main", "Tariff", err)
+	}
+	if nightEnd, err = parseShiftTime(t.NightEnd); err != nil {
+		clog.Fatal("main", "Tariff", err)
+	}
+	nightRate = true
+}
+
+// tariffEnabled : Vérification des montants demandée
+func tariffEnabled() bool {
+	t := conf.Tariff
+	return t.PickUpFee > 0 || t.PerKm > 0 || t.PerMin > 0
+}
+
+// isNight : La prise en charge est dans la plage du tarif de nuit
+func isNight(at time.Time) bool {
+	if !nightRate {
+		return false
+	}
+	m := at.Hour()*60 + at.Minute()
+	if nightStart <= nightEnd {
+		return m >= nightStart && m < nightEnd
+	}
+	return m >= nightStart || m < nightEnd
+}
+
+// expectedFare : Montant TTC attendu pour la course simulée.
+// Le tarif de nuit dépend de l'heure réelle de prise en charge, celle que voit le serveur.
+func expectedFare(m rideMeter) float64 {
+	t := conf.Tariff
+	fare := t.PickUpFee + t.PerKm*m.Km + t.PerMin*m.minutes()
+	if isNight(m.PickedUp) {
+		fare *= 1 + t.NightSurcharge/100
+	}
+	return fare * (1 + t.VAT/100)
+}
+
+// paymentStats : Statistiques de paiement, dans payment ou à la racine selon la version du serveur
+func paymentStats(resp datamodels.PendingPaymentResponse) [3]datamodels.RideStats {
+	for _, s := range resp.Payment.Stats {
+		if s.Unit != "" || s.Value != 0 {
+			return resp.Payment.Stats
+		}
+	}
+	return resp.Stats
+}
+
+// statValue : Valeur de la statistique du type demandé, quel que soit son rang dans le tableau
+func statValue(stats [3]datamodels.RideStats, statType int) float64 {
+	for _, s := range stats {
+		if s.Type == statType && (s.Unit != "" || s.Value != 0) {
+			return float64(s.Value)
+		}
+	}
+	return 0
+}
+
+// fareCheck : Montant attendu et facturé d'une course
+type fareCheck struct {
+	ExternalID string
+	DriverID   int
+	Meter      rideMeter
+	Night      bool
+	Expected   float64
+	Billed     float64
+	VAT        float64 // Taux de TVA retourné dans payment, 0 si absent
+}
+
+// gap : Ecart relatif en % entre le montant facturé et le montant attendu
+func (c fareCheck) gap() float64 {
+	if c.Expected == 0 {
+		return 0
+	}
+	return 100 * (c.Billed - c.Expected) / c.Expected
+}
+
+// vatMissing : Le serveur n'a pas donné de taux de TVA alors qu'il en faut un.
+// Les statistiques à la racine de la réponse n'en portent jamais.
+func (c fareCheck) vatMissing() bool {
+	return c.VAT == 0 && conf.Tariff.VAT != 0
+}
+
+func (c fareCheck) vatOK() bool {
+	return math.Abs(c.VAT-conf.Tariff.VAT) < 0.01
+}
+
+// FareChecks : Compare les montants facturés au tarif du bench
+type FareChecks struct {
+	mu     sync.Mutex
+	checks []fareCheck
+}
+
+// NewFareChecks : Creation de la vérification des montants
+func NewFareChecks() *FareChecks {
+	return &FareChecks{}
+}
+
+// billed : PendingPaymentResponse reçu pour une course terminée par le driver
+func (f *FareChecks) billed(externalID string, d *Driver, m rideMeter, resp datamodels.PendingPaymentResponse) {
+	stats := paymentStats(resp)
+	c := fareCheck{
+		ExternalID: externalID,
+		DriverID:   d.ID,
+		Meter:      m,
+		Night:      isNight(m.PickedUp),
+		Expected:   expectedFare(m),
+		Billed:     statValue(stats, statAmount),
+		VAT:        float64(resp.Payment.VatValue),
+	}
+
+	f.mu.Lock()
+	f.checks = append(f.checks, c)
+	f.mu.Unlock()
+}
+
+// Report : Courses facturées hors tolérance
+func (f *FareChecks) Report(w io.Writer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reportTitle(w, "Fares")
+
+	if !tariffEnabled() {
+		fmt.Fprintf(w, "No tariff configured, %d payments not checked\n", len(f.checks))
+		return true
+	}
+
+	var gaps samples
+	var wrong, badVAT []fareCheck
+	noVAT := 0
+	night := 0
+	for _, c := range f.checks {
+		gaps = append(gaps, c.gap())
+		if c.Night {
+			night++
+		}
+		if math.Abs(c.gap()) > conf.Tariff.Tolerance {
+			wrong = append(wrong, c)
+		}
+		if c.vatMissing() {
+			noVAT++
+		} else if !c.vatOK() {
+			badVAT = append(badVAT, c)
+		}
+	}
+
+	fmt.Fprintf(w, "Payments checked: %d (%d at night rate)\n", len(f.checks), night)
+	fmt.Fprintf(w, "  Billed vs expected %s\n", gaps.summary("%"))
+	fmt.Fprintf(w, "%s fares beyond %.1f%%: %d\n", passFail(len(wrong) == 0), conf.Tariff.Tolerance, len(wrong))
+	for i, c := range wrong {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(wrong)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %s driver %d: %.2f km %.1f min night %v, expected %.2f billed %.2f (%+.1f%%)\n",
+			c.ExternalID, c.DriverID, c.Meter.Km, c.Meter.minutes(), c.Night, c.Expected, c.Billed, c.gap())
+	}
+	fmt.Fprintf(w, "%s VAT not provided: %d\n", passFail(noVAT == 0), noVAT)
+	fmt.Fprintf(w, "%s VAT rate differs from %.1f%%: %d\n", passFail(len(badVAT) == 0), conf.Tariff.VAT, len(badVAT))
+	for i, c := range badVAT {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(badVAT)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %s driver %d: VAT %.1f%%\n", c.ExternalID, c.DriverID, c.VAT)
+	}
+	return len(wrong) == 0 && len(badVAT) == 0 && noVAT == 0
+}
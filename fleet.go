@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/confload"
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// driverGroup : Groupe de drivers de la conf, avec les noms résolus
+type driverGroup struct {
+	datamodels.DriverGroup
+	Name           string
+	vehicleType    datamodels.VehicleType
+	vehicleOptions []datamodels.VehicleOption
+	behaviour      *behaviour
+	shift          bool // Service planifié par ShiftStart / ShiftEnd
+	shiftStart     int  // Minutes depuis minuit
+	shiftEnd       int
+	gpsName        string
+	gps            geoloc.GPSProfile
+}
+
+// defaultGroup : Groupe des drivers non couverts par les sections [Group.*]
+var defaultGroup = &driverGroup{
+	DriverGroup: datamodels.DriverGroup{
+		VehicleType:    "Berline",
+		VehicleOptions: []string{"CovidShield"},
+		Brand:          "Peugeot",
+		Model:          "508",
+		Color:          "BLACK",
+		Plate:          "BD",
+		Seats:          4,
+		Luggages:       3,
+	},
+	Name:           "default",
+	vehicleType:    datamodels.Berline,
+	vehicleOptions: []datamodels.VehicleOption{datamodels.CovidShield},
+	behaviour:      defaultBehaviour,
+}
+
+// fleet : Groupes dans l'ordre du fichier de conf
+var fleet []*driverGroup
+
+func newDriverGroup(name string, g datamodels.DriverGroup) (*driverGroup, error) {
+	group := &driverGroup{DriverGroup: g, Name: name}
+
+	vt, has := datamodels.VehicleTypes[g.VehicleType]
+	if !has {
+		return nil, fmt.Errorf("group %s: unknown vehicle type %q", name, g.VehicleType)
+	}
+	group.vehicleType = vt
+
+	for _, o := range g.VehicleOptions {
+		opt, has := datamodels.VehicleOptions[o]
+		if !has {
+			return nil, fmt.Errorf("group %s: unknown vehicle option %q", name, o)
+		}
+		group.vehicleOptions = append(group.vehicleOptions, opt)
+	}
+
+	b, err := behaviourFor(g.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("group %s: %s", name, err)
+	}
+	group.behaviour = b
+
+	if group.gps, err = gpsProfileFor(g.GPS); err != nil {
+		return nil, fmt.Errorf("group %s: %s", name, err)
+	}
+	group.gpsName = g.GPS
+
+	if g.Zone != "" && zones.Named(g.Zone) == nil {
+		return nil, fmt.Errorf("group %s: unknown zone %q", name, g.Zone)
+	}
+
+	if g.ShiftStart != "" || g.ShiftEnd != "" {
+		if group.shiftStart, err = parseShiftTime(g.ShiftStart); err != nil {
+			return nil, fmt.Errorf("group %s: %s", name, err)
+		}
+		if group.shiftEnd, err = parseShiftTime(g.ShiftEnd); err != nil {
+			return nil, fmt.Errorf("group %s: %s", name, err)
+		}
+		group.shift = true
+	}
+	return group, nil
+}
+
+// loadFleet : Charge les sections [Group.nom] du fichier de conf
+func loadFleet() {
+	total := 0
+	for _, name := range confload.Children("Group") {
+		g := datamodels.DriverGroup{VehicleType: "Berline", Seats: 4, Luggages: 3}
+		if err := confload.LoadSection("Group."+name, &g); err != nil {
+			clog.Fatal("main", "Fleet", err)
+		}
+		group, err := newDriverGroup(name, g)
+		if err != nil {
+			clog.Fatal("main", "Fleet", err)
+		}
+		fleet = append(fleet, group)
+		total += g.Count
+	}
+
+	if total > conf.Bench.NbDrivers {
+		clog.Warn("main", "Fleet", "%d drivers in groups but NbDrivers is %d", total, conf.Bench.NbDrivers)
+	}
+	clog.Info("main", "Fleet", "%d groups, %d drivers in groups", len(fleet), total)
+}
+
+// groupFor : Groupe du driver n, les groupes sont remplis dans l'ordre
+func groupFor(id int) *driverGroup {
+	n := 0
+	for _, g := range fleet {
+		n += g.Count
+		if id <= n {
+			return g
+		}
+	}
+	return defaultGroup
+}
+
+// equip : Affecte le véhicule du groupe au driver
+func (g *driverGroup) equip(d *Driver) {
+	d.Group = g
+	d.VehicleType = g.vehicleType
+	d.VehicleOptions = g.vehicleOptions
+	d.gps = geoloc.NewGPSSensor(g.gps, rand.Int63())
+	if g.Zone != "" && conf.Bench.Scenario != scenarioHerd {
+		d.Coord = addressInZone(g.Zone).Coord
+	}
+	d.Vehicle = datamodels.Vehicle{
+		ID:           d.ID,
+		VehicleType:  g.vehicleType,
+		Brand:        g.Brand,
+		Model:        g.Model,
+		Color:        g.Color,
+		Plate:        fmt.Sprintf("%s-%03d", g.Plate, d.ID),
+		NumberOfSeat: g.Seats,
+	}
+}
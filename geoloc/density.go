@@ -0,0 +1,138 @@
+package geoloc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// densityCell : Cellule de la grille de densité, carré autour d'un centre ou zone GeoJSON
+type densityCell struct {
+	lat, lng float64 // Centre d'une cellule raster
+	zone     *Zone
+}
+
+// Density : Grille de densité de population, tire des points proportionnellement aux poids
+type Density struct {
+	cells []densityCell
+	cumul []float64 // Poids cumulés
+	half  float64   // Demi-côté en degrés des cellules raster
+}
+
+func (d *Density) add(c densityCell, weight float64) {
+	if weight <= 0 {
+		return
+	}
+	total := weight
+	if n := len(d.cumul); n > 0 {
+		total += d.cumul[n-1]
+	}
+	d.cells = append(d.cells, c)
+	d.cumul = append(d.cumul, total)
+}
+
+// Len : Nb de cellules de poids non nul
+func (d *Density) Len() int {
+	return len(d.cells)
+}
+
+// LoadDensityCSV : Raster longitude;latitude;poids, centres de cellules de cellMeters de côté.
+// Une première ligne non numérique est prise pour un en-tête.
+func LoadDensityCSV(path string, cellMeters float64) (*Density, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = ';'
+	r.FieldsPerRecord = 3
+
+	d := &Density{half: cellMeters / coeffPyth / 2}
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		var v [3]float64
+		for i := range v {
+			if v[i], err = strconv.ParseFloat(strings.TrimSpace(record[i]), 64); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s line %d: %s", path, line, err)
+		}
+		d.add(densityCell{lat: v[1], lng: v[0]}, v[2])
+	}
+	if d.Len() == 0 {
+		return nil, fmt.Errorf("%s: no weighted cell", path)
+	}
+	return d, nil
+}
+
+// LoadDensityGeoJSON : Polygones pondérés par leur propriété weight, demand à défaut
+func LoadDensityGeoJSON(path string) (*Density, error) {
+	zones, err := LoadZones(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &Density{}
+	for _, z := range zones {
+		w := z.weight
+		if w == 0 {
+			w = z.Demand
+		}
+		d.add(densityCell{zone: z}, w)
+	}
+	if d.Len() == 0 {
+		return nil, fmt.Errorf("%s: no weighted polygon", path)
+	}
+	return d, nil
+}
+
+// Sample : Point aléatoire, cellule tirée selon son poids puis position uniforme dans la cellule
+func (d *Density) Sample() (lat, lng float64) {
+	total := d.cumul[len(d.cumul)-1]
+	n := rand.Float64() * total
+	i := 0
+	for lo, hi := 0, len(d.cumul)-1; lo <= hi; {
+		mid := (lo + hi) / 2
+		if d.cumul[mid] > n {
+			i = mid
+			hi = mid - 1
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	c := d.cells[i]
+	if c.zone == nil {
+		lat = c.lat + (rand.Float64()*2-1)*d.half
+		lng = c.lng + (rand.Float64()*2-1)*d.half/math.Cos(degreesToRadians(c.lat))
+		return lat, lng
+	}
+
+	// Tirage dans l'emprise jusqu'à tomber dans la zone
+	z := c.zone
+	for try := 0; try < 100; try++ {
+		lat = z.minLat + rand.Float64()*(z.maxLat-z.minLat)
+		lng = z.minLng + rand.Float64()*(z.maxLng-z.minLng)
+		if z.Contains(lat, lng) {
+			return lat, lng
+		}
+	}
+	return (z.minLat + z.maxLat) / 2, (z.minLng + z.maxLng) / 2
+}
@@ -21,7 +21,7 @@ func hsin(theta float64) float64 {
 // DistanceSimple : Calcul de la distance en metre entre 2 points GPS en utilisant Pythagore
 func DistanceSimple(lat1, long1, lat2, long2 float64) float64 {
 	deltaY := lat2 - lat1
-	deltaX := (long1 - long2) * math.Cos((lat1+lat2)/2)
+	deltaX := (long1 - long2) * math.Cos(degreesToRadians((lat1+lat2)/2))
 	dist := math.Sqrt(deltaX*deltaX + deltaY*deltaY)
 
 	return coeffPyth * dist
@@ -38,7 +38,7 @@ func DistanceAccurate(lat1, lon1, lat2, lon2 float64) float64 {
 	return coeffHav * math.Asin(math.Sqrt(h))
 }
 
-// DistanceFromHome :
+// DistanceFromHome : Distance en mètres au point de référence
 func DistanceFromHome(lat float64, long float64) float64 {
 	return DistanceSimple(lat, long, maisonLat, maisonLng)
 }
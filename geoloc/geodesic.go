@@ -0,0 +1,172 @@
+package geoloc
+
+import (
+	"math"
+)
+
+// earthRadius : Rayon terrestre en mètres du modèle sphérique, celui de DistanceAccurate
+const earthRadius = 6378100.0
+
+// Ellipsoïde WGS84
+const (
+	wgs84A = 6378137.0         // Demi grand axe en mètres
+	wgs84F = 1 / 298.257223563 // Aplatissement
+	wgs84B = wgs84A * (1 - wgs84F)
+)
+
+func radiansToDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// normalizeBearing : Cap ramené dans [0, 360[
+func normalizeBearing(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// normalizeLng : Longitude ramenée dans [-180, 180[
+func normalizeLng(deg float64) float64 {
+	return math.Mod(math.Mod(deg+180, 360)+360, 360) - 180
+}
+
+// Bearing : Cap initial en degrés (0 au nord, sens horaire) du grand cercle de 1 vers 2
+func Bearing(lat1, lng1, lat2, lng2 float64) float64 {
+	la1 := degreesToRadians(lat1)
+	la2 := degreesToRadians(lat2)
+	dl := degreesToRadians(lng2 - lng1)
+
+	y := math.Sin(dl) * math.Cos(la2)
+	x := math.Cos(la1)*math.Sin(la2) - math.Sin(la1)*math.Cos(la2)*math.Cos(dl)
+	return normalizeBearing(radiansToDegrees(math.Atan2(y, x)))
+}
+
+// FinalBearing : Cap en degrés à l'arrivée en 2 du grand cercle venant de 1
+func FinalBearing(lat1, lng1, lat2, lng2 float64) float64 {
+	return normalizeBearing(Bearing(lat2, lng2, lat1, lng1) + 180)
+}
+
+// Destination : Point atteint depuis lat, lng en suivant le cap bearing (degrés) sur meters mètres
+func Destination(lat, lng, bearing, meters float64) (float64, float64) {
+	la := degreesToRadians(lat)
+	lo := degreesToRadians(lng)
+	b := degreesToRadians(bearing)
+	d := meters / earthRadius
+
+	la2 := math.Asin(math.Sin(la)*math.Cos(d) + math.Cos(la)*math.Sin(d)*math.Cos(b))
+	lo2 := lo + math.Atan2(math.Sin(b)*math.Sin(d)*math.Cos(la), math.Cos(d)-math.Sin(la)*math.Sin(la2))
+	return radiansToDegrees(la2), normalizeLng(radiansToDegrees(lo2))
+}
+
+// Midpoint : Milieu du grand cercle entre 1 et 2
+func Midpoint(lat1, lng1, lat2, lng2 float64) (float64, float64) {
+	return Intermediate(lat1, lng1, lat2, lng2, 0.5)
+}
+
+// Intermediate : Point à la fraction f (0 en 1, 1 en 2) du grand cercle entre 1 et 2
+func Intermediate(lat1, lng1, lat2, lng2, f float64) (float64, float64) {
+	la1, lo1 := degreesToRadians(lat1), degreesToRadians(lng1)
+	la2, lo2 := degreesToRadians(lat2), degreesToRadians(lng2)
+
+	d := DistanceAccurate(lat1, lng1, lat2, lng2) / earthRadius
+	if d == 0 {
+		return lat1, lng1
+	}
+	a := math.Sin((1-f)*d) / math.Sin(d)
+	b := math.Sin(f*d) / math.Sin(d)
+
+	x := a*math.Cos(la1)*math.Cos(lo1) + b*math.Cos(la2)*math.Cos(lo2)
+	y := a*math.Cos(la1)*math.Sin(lo1) + b*math.Cos(la2)*math.Sin(lo2)
+	z := a*math.Sin(la1) + b*math.Sin(la2)
+	return radiansToDegrees(math.Atan2(z, math.Hypot(x, y))), radiansToDegrees(math.Atan2(y, x))
+}
+
+// BoundingBox : Emprise des points à moins de meters mètres de lat, lng.
+// Aux pôles l'emprise couvre toutes les longitudes ; minLng > maxLng si elle passe l'antiméridien.
+func BoundingBox(lat, lng, meters float64) (minLat, minLng, maxLat, maxLng float64) {
+	d := radiansToDegrees(meters / earthRadius)
+	minLat, maxLat = lat-d, lat+d
+	if minLat <= -90 || maxLat >= 90 {
+		return math.Max(minLat, -90), -180, math.Min(maxLat, 90), 180
+	}
+
+	// Ecart de longitude maxi, atteint à la tangence du cercle avec un méridien
+	dl := radiansToDegrees(math.Asin(math.Sin(meters/earthRadius) / math.Cos(degreesToRadians(lat))))
+	return minLat, normalizeLng(lng - dl), maxLat, normalizeLng(lng + dl)
+}
+
+// PolygonArea : Surface en m² d'un contour [longitude, latitude] comme en GeoJSON, sur la sphère
+func PolygonArea(points [][2]float64) float64 {
+	n := len(points)
+	if n < 3 {
+		return 0
+	}
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		p1, p2 := points[i], points[(i+1)%n]
+		sum += degreesToRadians(p2[0]-p1[0]) * (2 + math.Sin(degreesToRadians(p1[1])) + math.Sin(degreesToRadians(p2[1])))
+	}
+	return math.Abs(sum * earthRadius * earthRadius / 2)
+}
+
+// Area : Surface en m² de la zone, trous déduits
+func (z *Zone) Area() float64 {
+	area := 0.0
+	for _, p := range z.polygons {
+		for i, r := range p {
+			if i == 0 {
+				area += PolygonArea(r)
+			} else {
+				area -= PolygonArea(r)
+			}
+		}
+	}
+	return area
+}
+
+// DistanceVincenty : Distance en mètres sur l'ellipsoïde WGS84 (formule inverse de Vincenty).
+// ok est faux si le calcul ne converge pas, pour des points presque antipodaux.
+func DistanceVincenty(lat1, lng1, lat2, lng2 float64) (meters float64, ok bool) {
+	l := degreesToRadians(lng2 - lng1)
+	u1 := math.Atan((1 - wgs84F) * math.Tan(degreesToRadians(lat1)))
+	u2 := math.Atan((1 - wgs84F) * math.Tan(degreesToRadians(lat2)))
+	sinU1, cosU1 := math.Sin(u1), math.Cos(u1)
+	sinU2, cosU2 := math.Sin(u2), math.Cos(u2)
+
+	lambda := l
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda := math.Sin(lambda), math.Cos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0, true // Points confondus
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha // 0 sur l'équateur
+		}
+		c := wgs84F / 16 * cosSqAlpha * (4 + wgs84F*(4-3*cosSqAlpha))
+		prev := lambda
+		lambda = l + (1-c)*wgs84F*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < 1e-12 {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+
+	uSq := cosSqAlpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	a := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	b := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := b * sinSigma * (cos2SigmaM + b/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		b/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	return wgs84B * a * (sigma - deltaSigma), true
+}
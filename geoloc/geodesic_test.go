@@ -0,0 +1,201 @@
+package geoloc
+
+import (
+	"math"
+	"testing"
+)
+
+// Land's End et John o' Groats, exemple de référence des formules de grand cercle
+const (
+	landsEndLat = 50.06638889
+	landsEndLng = -5.71472222
+	johnLat     = 58.64388889
+	johnLng     = -3.07000000
+)
+
+// Flinders Peak et Buninyong, ligne de référence de Vincenty (1975)
+const (
+	flindersLat  = -37.95103341666667
+	flindersLng  = 144.42486788888889
+	buninyongLat = -37.65282113888889
+	buninyongLng = 143.92649552777777
+)
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestBearing(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		initial, final         float64
+	}{
+		{"north", 0, 0, 10, 0, 0, 0},
+		{"east on equator", 0, 0, 0, 90, 90, 90},
+		{"west on equator", 0, 0, 0, -90, 270, 270},
+		{"south", 10, 5, -10, 5, 180, 180},
+		// 009°07′11″ au départ, 011°16′31″ à l'arrivée
+		{"land's end to john o' groats", landsEndLat, landsEndLng, johnLat, johnLng, 9.1198, 11.2753},
+	}
+	for _, tt := range tests {
+		if got := Bearing(tt.lat1, tt.lng1, tt.lat2, tt.lng2); !near(got, tt.initial, 1e-3) {
+			t.Errorf("%s: Bearing = %f, want %f", tt.name, got, tt.initial)
+		}
+		if got := FinalBearing(tt.lat1, tt.lng1, tt.lat2, tt.lng2); !near(got, tt.final, 1e-3) {
+			t.Errorf("%s: FinalBearing = %f, want %f", tt.name, got, tt.final)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	quarter := earthRadius * math.Pi / 2
+	tests := []struct {
+		name             string
+		lat, lng         float64
+		bearing, meters  float64
+		wantLat, wantLng float64
+	}{
+		{"quarter east on equator", 0, 0, 90, quarter, 0, 90},
+		{"quarter north to the pole", 0, 0, 0, quarter, 90, 0},
+		{"one degree south", 10, 20, 180, earthRadius * math.Pi / 180, 9, 20},
+		{"across the antimeridian", 0, 179, 90, 2 * earthRadius * math.Pi / 180, 0, -179},
+		// 53°19′14″N 001°43′47″W, cap 096°01′18″, 124,8 km sur une sphère de 6371 km : 53°11′18″N 000°08′00″E
+		{"reference", 53.32055556, -1.72972222, 96.02166667, 124800 / 6371000.0 * earthRadius, 53.18833333, 0.13333333},
+	}
+	for _, tt := range tests {
+		lat, lng := Destination(tt.lat, tt.lng, tt.bearing, tt.meters)
+		if !near(lat, tt.wantLat, 1e-3) || !near(lng, tt.wantLng, 1e-3) {
+			t.Errorf("%s: Destination = %f, %f, want %f, %f", tt.name, lat, lng, tt.wantLat, tt.wantLng)
+		}
+	}
+}
+
+func TestIntermediate(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		f                      float64
+		wantLat, wantLng       float64
+	}{
+		{"start", landsEndLat, landsEndLng, johnLat, johnLng, 0, landsEndLat, landsEndLng},
+		{"end", landsEndLat, landsEndLng, johnLat, johnLng, 1, johnLat, johnLng},
+		// 54°21′44″N 004°31′50″W
+		{"land's end midpoint", landsEndLat, landsEndLng, johnLat, johnLng, 0.5, 54.36222, -4.53056},
+		{"quarter of equator arc", 0, 0, 0, 90, 0.25, 0, 22.5},
+		{"same point", 43.3, 5.4, 43.3, 5.4, 0.5, 43.3, 5.4},
+	}
+	for _, tt := range tests {
+		lat, lng := Intermediate(tt.lat1, tt.lng1, tt.lat2, tt.lng2, tt.f)
+		if !near(lat, tt.wantLat, 1e-3) || !near(lng, tt.wantLng, 1e-3) {
+			t.Errorf("%s: Intermediate = %f, %f, want %f, %f", tt.name, lat, lng, tt.wantLat, tt.wantLng)
+		}
+	}
+
+	lat, lng := Midpoint(landsEndLat, landsEndLng, johnLat, johnLng)
+	if !near(lat, 54.36222, 1e-3) || !near(lng, -4.53056, 1e-3) {
+		t.Errorf("Midpoint = %f, %f, want 54.36222, -4.53056", lat, lng)
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	degree := earthRadius * math.Pi / 180
+	tests := []struct {
+		name                           string
+		lat, lng, meters               float64
+		minLat, minLng, maxLat, maxLng float64
+	}{
+		{"equator", 0, 0, degree, -1, -1, 1, 1},
+		// asin(sin 1° / cos 60°)
+		{"60 north", 60, 10, degree, 59, 10 - 2.00030, 61, 10 + 2.00030},
+		{"north pole", 89.5, 0, degree, 88.5, -180, 90, 180},
+		{"south pole", -89.5, 30, degree, -90, -180, -88.5, 180},
+		{"antimeridian", 0, 179.5, degree, -1, 178.5, 1, -179.5},
+	}
+	for _, tt := range tests {
+		minLat, minLng, maxLat, maxLng := BoundingBox(tt.lat, tt.lng, tt.meters)
+		if !near(minLat, tt.minLat, 1e-4) || !near(minLng, tt.minLng, 1e-4) ||
+			!near(maxLat, tt.maxLat, 1e-4) || !near(maxLng, tt.maxLng, 1e-4) {
+			t.Errorf("%s: BoundingBox = %f, %f, %f, %f, want %f, %f, %f, %f", tt.name,
+				minLat, minLng, maxLat, maxLng, tt.minLat, tt.minLng, tt.maxLat, tt.maxLng)
+		}
+	}
+}
+
+func TestPolygonArea(t *testing.T) {
+	r2 := earthRadius * earthRadius
+	small := 0.01 * math.Pi / 180 * earthRadius
+	tests := []struct {
+		name   string
+		points [][2]float64
+		want   float64
+	}{
+		{"degenerate", [][2]float64{{0, 0}, {1, 1}}, 0},
+		// Rectangle en latitude / longitude : R² Δλ (sin φ2 - sin φ1)
+		{"one degree square on equator", [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}}, r2 * math.Pi / 180 * math.Sin(math.Pi/180)},
+		{"closed ring", [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}}, r2 * math.Pi / 180 * math.Sin(math.Pi/180)},
+		{"clockwise", [][2]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}}, r2 * math.Pi / 180 * math.Sin(math.Pi/180)},
+		{"square from 45 to 46 north", [][2]float64{{5, 45}, {6, 45}, {6, 46}, {5, 46}},
+			r2 * math.Pi / 180 * (math.Sin(46*math.Pi/180) - math.Sin(45*math.Pi/180))},
+		{"small right triangle", [][2]float64{{0, 0}, {0.01, 0}, {0, 0.01}}, small * small / 2},
+	}
+	for _, tt := range tests {
+		got := PolygonArea(tt.points)
+		if tt.want == 0 && got != 0 || tt.want != 0 && math.Abs(got-tt.want)/tt.want > 1e-3 {
+			t.Errorf("%s: PolygonArea = %f, want %f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceVincenty(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+		ok                     bool
+	}{
+		{"flinders peak to buninyong", flindersLat, flindersLng, buninyongLat, buninyongLng, 54972.271, true},
+		{"same point", 43.3, 5.4, 43.3, 5.4, 0, true},
+		// a π / 2
+		{"quarter of equator", 0, 0, 0, 90, 10018754.171, true},
+		// Quart de méridien WGS84
+		{"quarter of meridian", 0, 0, 90, 0, 10001965.729, true},
+		{"near antipodal", 0, 0, 0.5, 179.7, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := DistanceVincenty(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+		if ok != tt.ok {
+			t.Errorf("%s: DistanceVincenty ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && !near(got, tt.want, 1e-3) {
+			t.Errorf("%s: DistanceVincenty = %.4f, want %.3f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDistanceSimple(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lng1, lat2, lng2 float64
+		want                   float64
+	}{
+		{"one degree of latitude", 43, 5, 44, 5, coeffPyth},
+		{"longitude on equator", 0, 0, 0, 0.01, 0.01 * coeffPyth},
+		// Le cosinus porte sur la latitude moyenne en radians : cos 60° = 0,5
+		{"longitude at 60 north", 60, 0, 60, 0.02, 0.01 * coeffPyth},
+		{"longitude at Marseille", 43.3, 5.37, 43.3, 5.39, 0.02 * coeffPyth * math.Cos(43.3*math.Pi/180)},
+	}
+	for _, tt := range tests {
+		if got := DistanceSimple(tt.lat1, tt.lng1, tt.lat2, tt.lng2); !near(got, tt.want, 1e-6*tt.want) {
+			t.Errorf("%s: DistanceSimple = %f, want %f", tt.name, got, tt.want)
+		}
+	}
+
+	// Proche de Haversine sur quelques km
+	simple := DistanceSimple(43.30, 5.37, 43.31, 5.39)
+	accurate := DistanceAccurate(43.30, 5.37, 43.31, 5.39)
+	if math.Abs(simple-accurate)/accurate > 0.005 {
+		t.Errorf("DistanceSimple = %f, DistanceAccurate = %f", simple, accurate)
+	}
+}
@@ -0,0 +1,113 @@
+package geoloc
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// GPSProfile : Défauts d'un capteur GPS de téléphone, section [GPS.nom]
+type GPSProfile struct {
+	Noise            float64 // Ecart type du bruit gaussien en mètres
+	OutlierPercent   float64 // Part des positions aberrantes
+	OutlierDistance  float64 // Ecart en mètres d'une position aberrante
+	Drift            float64 // Pas en mètres de la dérive, marche aléatoire d'une position à l'autre
+	DriftMax         float64 // Dérive maxi en mètres
+	LossPercent      float64 // Probabilité qu'une perte de signal commence à chaque lecture
+	LossLength       int     // Nb de lectures sans signal par perte
+	DuplicatePercent float64 // Part des positions envoyées deux fois
+	ReorderPercent   float64 // Part des positions retenues et envoyées après la suivante
+}
+
+// Fix : Position fournie par le capteur
+type Fix struct {
+	Latitude  float64
+	Longitude float64
+	Outlier   bool      // Position aberrante
+	Duplicate bool      // Copie de la position précédente
+	Late      bool      // Position retenue, envoyée après une plus récente
+	At        time.Time // Heure de la lecture, conservée par une position retenue
+}
+
+// GPSSensor : Capteur GPS d'un driver, garde la dérive, la perte de signal et la position retenue
+type GPSSensor struct {
+	profile  GPSProfile
+	rnd      *rand.Rand
+	driftLat float64 // Dérive en mètres
+	driftLng float64
+	lossLeft int
+	held     *Fix
+}
+
+// NewGPSSensor : Capteur suivant le profil, seed rend la séquence de défauts reproductible
+func NewGPSSensor(profile GPSProfile, seed int64) *GPSSensor {
+	return &GPSSensor{
+		profile: profile,
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// metersToDegrees : Décalage en degrés d'un déplacement en mètres à la latitude lat
+func metersToDegrees(lat, north, east float64) (float64, float64) {
+	return north / coeffPyth, east / (coeffPyth * math.Cos(degreesToRadians(lat)))
+}
+
+func (s *GPSSensor) percent(p float64) bool {
+	return p > 0 && s.rnd.Float64()*100 < p
+}
+
+// Read : Positions à envoyer pour la position réelle, dans l'ordre d'envoi.
+// lost est vrai pendant une perte de signal, aucune position n'est alors fournie.
+// at est l'heure de la lecture, reportée dans chaque Fix.
+func (s *GPSSensor) Read(lat, lng float64, at time.Time) (fixes []Fix, lost bool) {
+	p := s.profile
+
+	if s.lossLeft == 0 && s.percent(p.LossPercent) {
+		s.lossLeft = p.LossLength
+	}
+	if s.lossLeft > 0 {
+		s.lossLeft--
+		return nil, true
+	}
+
+	if p.Drift > 0 {
+		s.driftLat += s.rnd.NormFloat64() * p.Drift
+		s.driftLng += s.rnd.NormFloat64() * p.Drift
+		if d := math.Hypot(s.driftLat, s.driftLng); p.DriftMax > 0 && d > p.DriftMax {
+			s.driftLat *= p.DriftMax / d
+			s.driftLng *= p.DriftMax / d
+		}
+	}
+
+	north := s.driftLat + s.rnd.NormFloat64()*p.Noise
+	east := s.driftLng + s.rnd.NormFloat64()*p.Noise
+	fix := Fix{At: at}
+	if s.percent(p.OutlierPercent) {
+		angle := s.rnd.Float64() * 2 * math.Pi
+		north += p.OutlierDistance * math.Cos(angle)
+		east += p.OutlierDistance * math.Sin(angle)
+		fix.Outlier = true
+	}
+	dLat, dLng := metersToDegrees(lat, north, east)
+	fix.Latitude = lat + dLat
+	fix.Longitude = lng + dLng
+
+	if s.held == nil && s.percent(p.ReorderPercent) {
+		held := fix
+		held.Late = true
+		s.held = &held
+		return nil, false
+	}
+
+	fixes = append(fixes, fix)
+	if s.held != nil {
+		fixes = append(fixes, *s.held)
+		s.held = nil
+	}
+	if s.percent(p.DuplicatePercent) {
+		dup := fix
+		dup.Duplicate = true
+		fixes = append(fixes, dup)
+	}
+	return fixes, false
+}
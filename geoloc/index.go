@@ -0,0 +1,273 @@
+package geoloc
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// cellKey : Ligne et colonne d'une cellule de la grille
+type cellKey struct {
+	row, col int
+}
+
+// indexEntry : Point indexé
+type indexEntry struct {
+	id       int
+	lat, lng float64
+}
+
+// Neighbour : Résultat d'une recherche, point et distance en mètres
+type Neighbour struct {
+	ID     int
+	Meters float64
+}
+
+// Index : Index spatial en grille, cellules de taille fixe en degrés.
+// Pas de verrou, l'appelant synchronise les accès.
+type Index struct {
+	step    float64 // Taille d'une cellule en degrés
+	cells   map[cellKey][]indexEntry
+	entries map[int]indexEntry
+	min     cellKey // Emprise des cellules occupées, borne la recherche
+	max     cellKey
+}
+
+// NewIndex : Index de cellules d'environ cellMeters mètres de haut
+func NewIndex(cellMeters float64) *Index {
+	return &Index{
+		step:    cellMeters / coeffPyth,
+		cells:   make(map[cellKey][]indexEntry),
+		entries: make(map[int]indexEntry),
+	}
+}
+
+func (x *Index) key(lat, lng float64) cellKey {
+	return cellKey{int(math.Floor(lat / x.step)), int(math.Floor(lng / x.step))}
+}
+
+// Len : Nb de points indexés
+func (x *Index) Len() int {
+	return len(x.entries)
+}
+
+// Insert : Ajoute le point id, le déplace s'il est déjà indexé
+func (x *Index) Insert(id int, lat, lng float64) {
+	if old, has := x.entries[id]; has {
+		if old.lat == lat && old.lng == lng {
+			return
+		}
+		x.Remove(id)
+	}
+
+	k := x.key(lat, lng)
+	if len(x.entries) == 0 {
+		x.min, x.max = k, k
+	}
+	x.min.row = minInt(x.min.row, k.row)
+	x.min.col = minInt(x.min.col, k.col)
+	x.max.row = maxInt(x.max.row, k.row)
+	x.max.col = maxInt(x.max.col, k.col)
+
+	e := indexEntry{id, lat, lng}
+	x.cells[k] = append(x.cells[k], e)
+	x.entries[id] = e
+}
+
+// Move : Nouvelle position du point id
+func (x *Index) Move(id int, lat, lng float64) {
+	x.Insert(id, lat, lng)
+}
+
+// Remove : Retire le point id
+func (x *Index) Remove(id int) {
+	e, has := x.entries[id]
+	if !has {
+		return
+	}
+	delete(x.entries, id)
+
+	k := x.key(e.lat, e.lng)
+	cell := x.cells[k]
+	for i := range cell {
+		if cell[i].id == id {
+			cell[i] = cell[len(cell)-1]
+			cell = cell[:len(cell)-1]
+			break
+		}
+	}
+	if len(cell) > 0 {
+		x.cells[k] = cell
+		return
+	}
+	delete(x.cells, k)
+	if k.row == x.min.row || k.row == x.max.row || k.col == x.min.col || k.col == x.max.col {
+		x.extent()
+	}
+}
+
+// extent : Recalcule l'emprise des cellules occupées après qu'une cellule du bord s'est vidée
+func (x *Index) extent() {
+	first := true
+	for k := range x.cells {
+		if first {
+			x.min, x.max = k, k
+			first = false
+			continue
+		}
+		x.min.row = minInt(x.min.row, k.row)
+		x.min.col = minInt(x.min.col, k.col)
+		x.max.row = maxInt(x.max.row, k.row)
+		x.max.col = maxInt(x.max.col, k.col)
+	}
+}
+
+// Position : Position indexée du point id
+func (x *Index) Position(id int) (lat, lng float64, ok bool) {
+	e, ok := x.entries[id]
+	return e.lat, e.lng, ok
+}
+
+func (x *Index) visit(k cellKey, visit func(indexEntry)) {
+	for _, e := range x.cells[k] {
+		visit(e)
+	}
+}
+
+// ring : Cellules à distance r (en cellules) de la cellule centrale, seulement les bords
+// de l'anneau et limitées à l'emprise occupée
+func (x *Index) ring(c cellKey, r int, visit func(indexEntry)) {
+	if r == 0 {
+		x.visit(c, visit)
+		return
+	}
+	bottom, top := c.row-r, c.row+r
+	left, right := c.col-r, c.col+r
+
+	// Lignes du bas et du haut, coins compris
+	for col := maxInt(left, x.min.col); col <= minInt(right, x.max.col); col++ {
+		if bottom >= x.min.row {
+			x.visit(cellKey{bottom, col}, visit)
+		}
+		if top <= x.max.row {
+			x.visit(cellKey{top, col}, visit)
+		}
+	}
+	// Colonnes de gauche et de droite, sans les coins
+	for row := maxInt(bottom+1, x.min.row); row <= minInt(top-1, x.max.row); row++ {
+		if left >= x.min.col {
+			x.visit(cellKey{row, left}, visit)
+		}
+		if right <= x.max.col {
+			x.visit(cellKey{row, right}, visit)
+		}
+	}
+}
+
+// maxRing : Anneau au-delà duquel plus aucune cellule n'est occupée
+func (x *Index) maxRing(c cellKey) int {
+	return maxInt(maxInt(c.row-x.min.row, x.max.row-c.row), maxInt(c.col-x.min.col, x.max.col-c.col))
+}
+
+// ringMeters : Distance mini en mètres d'un point de la cellule c aux cellules de l'anneau r.
+// L'écart en longitude est compté à la latitude la plus haute de l'anneau, où il est le plus court.
+func (x *Index) ringMeters(c cellKey, r int) float64 {
+	if r <= 1 {
+		return 0
+	}
+	highest := math.Max(math.Abs(float64(c.row-r)*x.step), math.Abs(float64(c.row+r+1)*x.step))
+	return float64(r-1) * x.step * coeffPyth * math.Cos(degreesToRadians(math.Min(highest, 89)))
+}
+
+// Nearest : Point le plus proche et sa distance en mètres, ok faux si l'index est vide
+func (x *Index) Nearest(lat, lng float64) (id int, meters float64, ok bool) {
+	n := x.NearestK(lat, lng, 1, nil)
+	if len(n) == 0 {
+		return 0, 0, false
+	}
+	return n[0].ID, n[0].Meters, true
+}
+
+// neighbourHeap : Tas max sur la distance, garde les k plus proches trouvés
+type neighbourHeap []Neighbour
+
+func (h neighbourHeap) Len() int            { return len(h) }
+func (h neighbourHeap) Less(i, j int) bool  { return h[i].Meters > h[j].Meters }
+func (h neighbourHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighbourHeap) Push(x interface{}) { *h = append(*h, x.(Neighbour)) }
+func (h *neighbourHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// NearestK : k points les plus proches retenus par filter (nil pour tous), du plus proche au plus loin
+func (x *Index) NearestK(lat, lng float64, k int, filter func(id int) bool) []Neighbour {
+	if k <= 0 || len(x.entries) == 0 {
+		return nil
+	}
+	c := x.key(lat, lng)
+	found := make(neighbourHeap, 0, k)
+	last := x.maxRing(c)
+	for r := 0; r <= last; r++ {
+		// Les anneaux suivants sont tous plus loin que le k-ième trouvé
+		if len(found) == k && x.ringMeters(c, r) > found[0].Meters {
+			break
+		}
+		x.ring(c, r, func(e indexEntry) {
+			if filter != nil && !filter(e.id) {
+				return
+			}
+			d := DistanceAccurate(lat, lng, e.lat, e.lng)
+			if len(found) < k {
+				heap.Push(&found, Neighbour{e.id, d})
+			} else if d < found[0].Meters {
+				found[0] = Neighbour{e.id, d}
+				heap.Fix(&found, 0)
+			}
+		})
+	}
+	sortNeighbours(found)
+	return found
+}
+
+// Within : Points à moins de meters mètres retenus par filter (nil pour tous), du plus proche au plus loin
+func (x *Index) Within(lat, lng, meters float64, filter func(id int) bool) []Neighbour {
+	if len(x.entries) == 0 {
+		return nil
+	}
+	c := x.key(lat, lng)
+	var found []Neighbour
+	last := x.maxRing(c)
+	for r := 0; r <= last && x.ringMeters(c, r) <= meters; r++ {
+		x.ring(c, r, func(e indexEntry) {
+			if filter != nil && !filter(e.id) {
+				return
+			}
+			if d := DistanceAccurate(lat, lng, e.lat, e.lng); d <= meters {
+				found = append(found, Neighbour{e.id, d})
+			}
+		})
+	}
+	sortNeighbours(found)
+	return found
+}
+
+func sortNeighbours(n []Neighbour) {
+	sort.Slice(n, func(i, j int) bool { return n[i].Meters < n[j].Meters })
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
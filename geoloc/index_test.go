@@ -0,0 +1,181 @@
+package geoloc
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// Points autour du Vieux-Port, cellules de 500 m
+func testIndex() *Index {
+	x := NewIndex(500)
+	x.Insert(1, 43.2951, 5.3744)
+	x.Insert(2, 43.2965, 5.3698)
+	x.Insert(3, 43.3050, 5.3950)
+	x.Insert(4, 43.2700, 5.3900)
+	x.Insert(5, 43.3400, 5.3600)
+	return x
+}
+
+func neighbourIDs(n []Neighbour) []int {
+	ids := make([]int, len(n))
+	for i := range n {
+		ids[i] = n[i].ID
+	}
+	return ids
+}
+
+func sameIDs(got, want []int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndexUpdates(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(x *Index)
+		len     int
+		nearest int
+	}{
+		{"insert", func(x *Index) {}, 5, 1},
+		{"insert again moves", func(x *Index) { x.Insert(5, 43.2950, 5.3743) }, 5, 5},
+		{"move", func(x *Index) { x.Move(4, 43.2950, 5.3743) }, 5, 4},
+		{"remove", func(x *Index) { x.Remove(1) }, 4, 2},
+		{"remove unknown", func(x *Index) { x.Remove(42) }, 5, 1},
+	}
+	for _, tt := range tests {
+		x := testIndex()
+		tt.update(x)
+		if x.Len() != tt.len {
+			t.Errorf("%s: Len = %d, want %d", tt.name, x.Len(), tt.len)
+		}
+		if got := x.NearestK(43.2950, 5.3743, 1, nil); len(got) != 1 || got[0].ID != tt.nearest {
+			t.Errorf("%s: nearest = %v, want %d", tt.name, neighbourIDs(got), tt.nearest)
+		}
+	}
+}
+
+func TestIndexExtent(t *testing.T) {
+	x := testIndex()
+	full := struct{ min, max cellKey }{x.min, x.max}
+
+	// Le point 5 est seul sur la ligne du haut, le point 4 seul sur celle du bas
+	x.Remove(5)
+	if x.max.row >= full.max.row {
+		t.Errorf("extent after Remove(5): max row = %d, want < %d", x.max.row, full.max.row)
+	}
+	x.Remove(4)
+	if x.min.row <= full.min.row {
+		t.Errorf("extent after Remove(4): min row = %d, want > %d", x.min.row, full.min.row)
+	}
+	x.Remove(1)
+	x.Remove(2)
+	x.Remove(3)
+	if x.Len() != 0 || len(x.cells) != 0 {
+		t.Errorf("after removing all: Len = %d, cells = %d", x.Len(), len(x.cells))
+	}
+
+	// Emprise repartie de zéro après avoir tout retiré
+	x.Insert(6, 48.8566, 2.3522)
+	k := x.key(48.8566, 2.3522)
+	if x.min != k || x.max != k {
+		t.Errorf("extent after reinsert = %v %v, want %v", x.min, x.max, k)
+	}
+	if got := x.NearestK(43.2950, 5.3743, 1, nil); len(got) != 1 || got[0].ID != 6 {
+		t.Errorf("nearest after reinsert = %v, want 6", neighbourIDs(got))
+	}
+}
+
+func TestIndexNearestK(t *testing.T) {
+	odd := func(id int) bool { return id%2 == 1 }
+	tests := []struct {
+		name     string
+		lat, lng float64
+		k        int
+		filter   func(id int) bool
+		want     []int
+	}{
+		{"nearest", 43.2950, 5.3743, 1, nil, []int{1}},
+		{"three nearest", 43.2950, 5.3743, 3, nil, []int{1, 2, 3}},
+		{"more than indexed", 43.2950, 5.3743, 10, nil, []int{1, 2, 3, 4, 5}},
+		{"filtered", 43.2950, 5.3743, 2, odd, []int{1, 3}},
+		{"far away", 48.8566, 2.3522, 1, nil, []int{5}},
+		{"none", 43.2950, 5.3743, 0, nil, nil},
+	}
+	x := testIndex()
+	for _, tt := range tests {
+		got := x.NearestK(tt.lat, tt.lng, tt.k, tt.filter)
+		if !sameIDs(neighbourIDs(got), tt.want) {
+			t.Errorf("%s: NearestK = %v, want %v", tt.name, neighbourIDs(got), tt.want)
+		}
+	}
+}
+
+func TestIndexWithin(t *testing.T) {
+	odd := func(id int) bool { return id%2 == 1 }
+	tests := []struct {
+		name     string
+		lat, lng float64
+		meters   float64
+		filter   func(id int) bool
+		want     []int
+	}{
+		{"same cell", 43.2950, 5.3743, 100, nil, []int{1}},
+		{"neighbour cells", 43.2950, 5.3743, 500, nil, []int{1, 2}},
+		{"several rings", 43.2950, 5.3743, 4000, nil, []int{1, 2, 3, 4}},
+		{"filtered", 43.2950, 5.3743, 4000, odd, []int{1, 3}},
+		{"empty area", 43.2000, 5.5000, 1000, nil, nil},
+	}
+	x := testIndex()
+	for _, tt := range tests {
+		got := x.Within(tt.lat, tt.lng, tt.meters, tt.filter)
+		if !sameIDs(neighbourIDs(got), tt.want) {
+			t.Errorf("%s: Within = %v, want %v", tt.name, neighbourIDs(got), tt.want)
+		}
+	}
+}
+
+// La recherche par anneaux rend la même chose qu'un parcours complet, y compris vers le pôle
+func TestIndexBruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, lat := range []float64{0, 43.3, 70, -80} {
+		x := NewIndex(1000)
+		points := make(map[int][2]float64)
+		for id := 0; id < 300; id++ {
+			p := [2]float64{lat + rnd.Float64()*2 - 1, rnd.Float64()*2 - 1}
+			points[id] = p
+			x.Insert(id, p[0], p[1])
+		}
+		for id := 0; id < 300; id += 3 {
+			x.Remove(id)
+			delete(points, id)
+		}
+
+		qLat, qLng := lat+rnd.Float64()-0.5, rnd.Float64()-0.5
+		var all []Neighbour
+		for id, p := range points {
+			all = append(all, Neighbour{id, DistanceAccurate(qLat, qLng, p[0], p[1])})
+		}
+		sort.Slice(all, func(i, j int) bool { return all[i].Meters < all[j].Meters })
+
+		if got := x.NearestK(qLat, qLng, 5, nil); !sameIDs(neighbourIDs(got), neighbourIDs(all[:5])) {
+			t.Errorf("lat %v: NearestK = %v, want %v", lat, neighbourIDs(got), neighbourIDs(all[:5]))
+		}
+		var within []Neighbour
+		for _, n := range all {
+			if n.Meters <= 20000 {
+				within = append(within, n)
+			}
+		}
+		if got := x.Within(qLat, qLng, 20000, nil); !sameIDs(neighbourIDs(got), neighbourIDs(within)) {
+			t.Errorf("lat %v: Within = %d points, want %d", lat, len(got), len(within))
+		}
+	}
+}
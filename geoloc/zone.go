@@ -0,0 +1,192 @@
+package geoloc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// Types de zone, propriété "kind" des features GeoJSON
+const (
+	ZoneService      = "service"
+	ZoneAirport      = "airport"
+	ZoneOutOfService = "out_of_service"
+)
+
+// ring : Contour fermé, points [longitude, latitude] comme en GeoJSON
+type ring [][2]float64
+
+// contains : Ray casting, le point est à l'intérieur du contour
+func (r ring) contains(lat, lng float64) bool {
+	in := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		xi, yi := r[i][0], r[i][1]
+		xj, yj := r[j][0], r[j][1]
+		if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+// polygon : Contour extérieur suivi des trous
+type polygon []ring
+
+func (p polygon) contains(lat, lng float64) bool {
+	if len(p) == 0 || !p[0].contains(lat, lng) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.contains(lat, lng) {
+			return false
+		}
+	}
+	return true
+}
+
+// Zone : Zone géographique chargée d'un fichier GeoJSON
+type Zone struct {
+	Name     string
+	Kind     string  // service, airport ou out_of_service
+	Demand   float64 // Poids de la zone dans la génération des courses
+	weight   float64 // Poids de densité de population
+	polygons []polygon
+	minLat   float64
+	maxLat   float64
+	minLng   float64
+	maxLng   float64
+}
+
+// Contains : Le point est dans un des polygones de la zone
+func (z *Zone) Contains(lat, lng float64) bool {
+	if lat < z.minLat || lat > z.maxLat || lng < z.minLng || lng > z.maxLng {
+		return false
+	}
+	for _, p := range z.polygons {
+		if p.contains(lat, lng) {
+			return true
+		}
+	}
+	return false
+}
+
+func (z *Zone) bounds() {
+	first := true
+	for _, p := range z.polygons {
+		for _, pt := range p[0] {
+			lng, lat := pt[0], pt[1]
+			if first {
+				z.minLat, z.maxLat, z.minLng, z.maxLng = lat, lat, lng, lng
+				first = false
+				continue
+			}
+			if lat < z.minLat {
+				z.minLat = lat
+			}
+			if lat > z.maxLat {
+				z.maxLat = lat
+			}
+			if lng < z.minLng {
+				z.minLng = lng
+			}
+			if lng > z.maxLng {
+				z.maxLng = lng
+			}
+		}
+	}
+}
+
+// Zones : Ensemble de zones, la première zone contenant un point l'emporte
+type Zones []*Zone
+
+// Find : Zone contenant le point, nil si aucune
+func (zs Zones) Find(lat, lng float64) *Zone {
+	for _, z := range zs {
+		if z.Contains(lat, lng) {
+			return z
+		}
+	}
+	return nil
+}
+
+// Named : Zone par son nom, nil si inconnue
+func (zs Zones) Named(name string) *Zone {
+	for _, z := range zs {
+		if z.Name == name {
+			return z
+		}
+	}
+	return nil
+}
+
+type geoJSONFeature struct {
+	Properties struct {
+		Name   string  `json:"name"`
+		Kind   string  `json:"kind"`
+		Demand float64 `json:"demand"`
+		Weight float64 `json:"weight"`
+	} `json:"properties"`
+	Geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// LoadZones : Charge les Polygon / MultiPolygon d'une FeatureCollection GeoJSON.
+// Propriétés lues : name, kind (service par défaut) et demand (1 par défaut).
+func LoadZones(path string) (Zones, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fc geoJSONCollection
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	var zones Zones
+	for i, f := range fc.Features {
+		z := &Zone{Name: f.Properties.Name, Kind: f.Properties.Kind, Demand: f.Properties.Demand, weight: f.Properties.Weight}
+		if z.Name == "" {
+			z.Name = fmt.Sprintf("zone%d", i+1)
+		}
+		if z.Kind == "" {
+			z.Kind = ZoneService
+		}
+		if z.Demand == 0 {
+			z.Demand = 1
+		}
+
+		switch f.Geometry.Type {
+		case "Polygon":
+			var p polygon
+			if err := json.Unmarshal(f.Geometry.Coordinates, &p); err != nil {
+				return nil, fmt.Errorf("%s: zone %s: %s", path, z.Name, err)
+			}
+			z.polygons = []polygon{p}
+		case "MultiPolygon":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &z.polygons); err != nil {
+				return nil, fmt.Errorf("%s: zone %s: %s", path, z.Name, err)
+			}
+		default:
+			continue
+		}
+		if len(z.polygons) == 0 {
+			return nil, fmt.Errorf("%s: zone %s: no polygon", path, z.Name)
+		}
+		for _, p := range z.polygons {
+			// Contour extérieur fermé : au moins 3 sommets plus le retour au premier
+			if len(p) == 0 || len(p[0]) < 4 {
+				return nil, fmt.Errorf("%s: zone %s: outer ring needs at least 4 points", path, z.Name)
+			}
+		}
+		z.bounds()
+		zones = append(zones, z)
+	}
+	return zones, nil
+}
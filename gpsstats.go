@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/confload"
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// gpsProfiles : Profils de capteur GPS de la conf par nom
+var gpsProfiles = map[string]geoloc.GPSProfile{}
+
+// loadGPSProfiles : Charge les sections [GPS.nom] du fichier de conf
+func loadGPSProfiles() {
+	for _, name := range confload.Children("GPS") {
+		var p geoloc.GPSProfile
+		if err := confload.LoadSection("GPS."+name, &p); err != nil {
+			clog.Fatal("main", "GPS", err)
+		}
+		gpsProfiles[name] = p
+	}
+	clog.Info("main", "GPS", "%d GPS profiles", len(gpsProfiles))
+}
+
+// gpsProfileFor : Profil nommé, capteur parfait si le nom est vide
+func gpsProfileFor(name string) (geoloc.GPSProfile, error) {
+	if name == "" {
+		return geoloc.GPSProfile{}, nil
+	}
+	p, has := gpsProfiles[name]
+	if !has {
+		return p, fmt.Errorf("unknown GPS profile %q", name)
+	}
+	return p, nil
+}
+
+// gpsCounts : Lectures du capteur pour un profil
+type gpsCounts struct {
+	reads, lost, sent          int
+	outliers, duplicates, late int
+	errors                     samples // Ecart en mètres entre la position envoyée et la position réelle
+}
+
+// GPSStats : Défauts GPS injectés par profil
+type GPSStats struct {
+	mu       sync.Mutex
+	profiles map[string]*gpsCounts
+}
+
+// NewGPSStats : Creation du suivi des capteurs GPS
+func NewGPSStats() *GPSStats {
+	return &GPSStats{
+		profiles: make(map[string]*gpsCounts),
+	}
+}
+
+// read : Lecture du capteur d'un driver à sa position réelle
+func (s *GPSStats) read(d *Driver, actual datamodels.Coordinates, fixes []geoloc.Fix, lost bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := d.Group.gpsName
+	c, has := s.profiles[name]
+	if !has {
+		c = &gpsCounts{}
+		s.profiles[name] = c
+	}
+
+	c.reads++
+	if lost {
+		c.lost++
+	}
+	for _, f := range fixes {
+		c.sent++
+		switch {
+		case f.Outlier:
+			c.outliers++
+		case f.Duplicate:
+			c.duplicates++
+		case f.Late:
+			c.late++
+		}
+		if !f.Late && !f.Duplicate {
+			c.errors = append(c.errors, geoloc.DistanceAccurate(actual.Latitude, actual.Longitude, f.Latitude, f.Longitude))
+		}
+	}
+}
+
+// Report : Défauts injectés et erreur de position par profil GPS
+func (s *GPSStats) Report(w io.Writer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reportTitle(w, "GPS sensor")
+
+	names := make([]string, 0, len(s.profiles))
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := s.profiles[name]
+		label := name
+		if label == "" {
+			label = "perfect"
+		}
+		fmt.Fprintf(w, "Profile %s: %d reads, %d lost, %d sent, %d outliers, %d duplicates, %d out of order\n",
+			label, c.reads, c.lost, c.sent, c.outliers, c.duplicates, c.late)
+		fmt.Fprintf(w, "  Position error %s\n", c.errors.summary("m"))
+	}
+	return true
+}
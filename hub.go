@@ -6,23 +6,89 @@ import (
 
 	"bench_dispatch/clog"
 	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
 	"bench_dispatch/gopool"
 )
 
+// driverIndexCell : Taille en mètres des cellules de l'index des drivers
+const driverIndexCell = 500
+
 // Hub :
 type Hub struct {
 	mu      sync.RWMutex
 	drivers map[int]*Driver
 
-	pool *gopool.Pool
+	geoMu sync.RWMutex
+	geo   *geoloc.Index // Dernières positions envoyées au serveur
+
+	pool       *gopool.Pool
+	logins     *LoginStats
+	violations *StateViolations
+	stuck      *StuckDrivers
+	ledger     *RideLedger
+	timelines  *RideTimelines
+	fanout     *FanOut
+	quality    *DispatchQuality
+	matching   *VehicleMatching
+	capacity   *RideCapacity
+	schedule   *ScheduledRides
+	cancels    *Cancellations
+	behaviours *BehaviourStats
+	shifts     *ShiftStats
+	fares      *FareChecks
+	distances  *RideDistances
+	gps        *GPSStats
+	zones      *ZoneStats
+	travel     *TravelTimes
+	tracks     *Trajectories
 }
 
 // NewHub : Creation du Hub de Driver
 func NewHub(pool *gopool.Pool) *Hub {
+	timelines := NewRideTimelines()
+	quality := NewDispatchQuality()
 	hub := &Hub{
-		pool:    pool,
-		drivers: make(map[int]*Driver),
+		pool:       pool,
+		drivers:    make(map[int]*Driver),
+		geo:        geoloc.NewIndex(driverIndexCell),
+		logins:     NewLoginStats(),
+		violations: NewStateViolations(),
+		stuck:      NewStuckDrivers(),
+		ledger:     NewRideLedger(),
+		timelines:  timelines,
+		fanout:     NewFanOut(),
+		quality:    quality,
+		matching:   NewVehicleMatching(),
+		capacity:   NewRideCapacity(),
+		schedule:   NewScheduledRides(),
+		cancels:    NewCancellations(),
+		behaviours: NewBehaviourStats(),
+		shifts:     NewShiftStats(),
+		fares:      NewFareChecks(),
+		distances:  NewRideDistances(),
+		gps:        NewGPSStats(),
+		zones:      NewZoneStats(timelines, quality),
+		travel:     NewTravelTimes(),
+		tracks:     NewTrajectories(),
 	}
+	addReporter(hub.logins)
+	addReporter(hub.violations)
+	addReporter(hub.stuck)
+	addReporter(hub.ledger)
+	addReporter(hub.timelines)
+	addReporter(hub.fanout)
+	addReporter(hub.quality)
+	addReporter(hub.matching)
+	addReporter(hub.capacity)
+	addReporter(hub.schedule)
+	addReporter(hub.cancels)
+	addReporter(hub.behaviours)
+	addReporter(hub.shifts)
+	addReporter(hub.fares)
+	addReporter(hub.distances)
+	addReporter(hub.gps)
+	addReporter(hub.zones)
+	addReporter(hub.travel)
 
 	clog.Info("main", "Hub", "Driver Hub initialized.")
 
@@ -31,12 +97,13 @@ func NewHub(pool *gopool.Pool) *Hub {
 
 // Register : registers new connection as a User.
 func (h *Hub) Register(conn net.Conn, id int, name string) *Driver {
-	loc := getNewAdress()
+	loc := scenarioAddress()
 	driver := &Driver{
 		hub:         h,
 		conn:        conn,
 		DriverState: datamodels.Offline,
 		Coord:       loc.Coord,
+		online:      true,
 	}
 	// driver.in = make(chan UserState, 1)
 
@@ -44,6 +111,7 @@ func (h *Hub) Register(conn net.Conn, id int, name string) *Driver {
 	{
 		driver.ID = id
 		driver.Name = name
+		groupFor(id).equip(driver)
 		h.drivers[driver.ID] = driver
 	}
 	h.mu.Unlock()
@@ -61,6 +129,7 @@ func (h *Hub) remove(driver *Driver) bool {
 		return false
 	}
 	delete(h.drivers, driver.ID)
+	h.unlocated(driver)
 	return true
 }
 
@@ -71,6 +140,48 @@ func (h *Hub) Remove(driver *Driver) {
 	h.mu.Unlock()
 }
 
+// located : Position envoyée au serveur par un driver
+func (h *Hub) located(driver *Driver, c datamodels.Coordinates) {
+	h.geoMu.Lock()
+	h.geo.Move(driver.ID, c.Latitude, c.Longitude)
+	h.geoMu.Unlock()
+}
+
+// unlocated : Driver déconnecté, le serveur ne peut plus le proposer
+func (h *Hub) unlocated(driver *Driver) {
+	h.geoMu.Lock()
+	h.geo.Remove(driver.ID)
+	h.geoMu.Unlock()
+}
+
+// nearby : Drivers indexés à moins de meters mètres, du plus proche au plus loin.
+// Si meters vaut 0, tous les drivers du Hub sans ordre, indexés ou non.
+func (h *Hub) nearby(c datamodels.Coordinates, meters float64) []*Driver {
+	if meters <= 0 {
+		h.mu.RLock()
+		drivers := make([]*Driver, 0, len(h.drivers))
+		for _, d := range h.drivers {
+			drivers = append(drivers, d)
+		}
+		h.mu.RUnlock()
+		return drivers
+	}
+
+	h.geoMu.RLock()
+	found := h.geo.Within(c.Latitude, c.Longitude, meters, nil)
+	h.geoMu.RUnlock()
+
+	drivers := make([]*Driver, 0, len(found))
+	h.mu.RLock()
+	for _, n := range found {
+		if d, has := h.drivers[n.ID]; has {
+			drivers = append(drivers, d)
+		}
+	}
+	h.mu.RUnlock()
+	return drivers
+}
+
 func (h *Hub) disconnectAll() {
 	for _, d := range h.drivers {
 		d.closeConnection()
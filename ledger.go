@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"bench_dispatch/datamodels"
+)
+
+// ledgerAccept : AcceptRide envoyé par un driver et réponses reçues
+type ledgerAccept struct {
+	DriverID  int
+	Name      string
+	SentAt    time.Time
+	Responses []int // Codes d'erreur des AcceptRideResponse reçues
+	Released  bool  // Course rendue par le driver après attribution
+	Expired   bool  // AcceptRide envoyé après l'expiration de l'offre
+}
+
+func (a *ledgerAccept) won() bool {
+	for _, code := range a.Responses {
+		if code == datamodels.ERR_SUCCESS.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// ledgerRide : Historique des acceptations d'une course
+type ledgerRide struct {
+	ID      int64
+	accepts []*ledgerAccept
+}
+
+func (r *ledgerRide) accept(driverID int) *ledgerAccept {
+	for _, a := range r.accepts {
+		if a.DriverID == driverID {
+			return a
+		}
+	}
+	return nil
+}
+
+// winners : Drivers détenant la course, hors courses rendues
+func (r *ledgerRide) winners() []*ledgerAccept {
+	var res []*ledgerAccept
+	for _, a := range r.accepts {
+		if a.won() && !a.Released {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func (r *ledgerRide) released() bool {
+	for _, a := range r.accepts {
+		if a.Released {
+			return true
+		}
+	}
+	return false
+}
+
+// expiredOnly : Course acceptée, mais uniquement après expiration de l'offre
+func (r *ledgerRide) expiredOnly() bool {
+	for _, a := range r.accepts {
+		if !a.Expired {
+			return false
+		}
+	}
+	return len(r.accepts) > 0
+}
+
+func (r *ledgerRide) answered() bool {
+	for _, a := range r.accepts {
+		if len(a.Responses) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RideLedger : Registre des AcceptRide par course pour vérifier l'attribution unique
+type RideLedger struct {
+	mu    sync.Mutex
+	rides map[int64]*ledgerRide
+	order []int64
+}
+
+// NewRideLedger : Creation du registre des courses
+func NewRideLedger() *RideLedger {
+	return &RideLedger{
+		rides: make(map[int64]*ledgerRide),
+	}
+}
+
+func (l *RideLedger) ride(id int64) *ledgerRide {
+	r, has := l.rides[id]
+	if !has {
+		r = &ledgerRide{ID: id}
+		l.rides[id] = r
+		l.order = append(l.order, id)
+	}
+	return r
+}
+
+// offered : Enregistre une course dès son NewRide, même si aucun driver ne l'accepte
+func (l *RideLedger) offered(rideID int64) {
+	l.mu.Lock()
+	l.ride(rideID)
+	l.mu.Unlock()
+}
+
+// accepted : Enregistre l'envoi d'un AcceptRide, expired s'il part après l'expiration de l'offre
+func (l *RideLedger) accepted(d *Driver, rideID int64, expired bool) {
+	l.mu.Lock()
+	r := l.ride(rideID)
+	if r.accept(d.ID) == nil {
+		r.accepts = append(r.accepts, &ledgerAccept{DriverID: d.ID, Name: d.Name, SentAt: time.Now(), Expired: expired})
+	}
+	l.mu.Unlock()
+}
+
+// answered : Enregistre la réponse du serveur à un AcceptRide
+func (l *RideLedger) answered(d *Driver, rideID int64, code int) {
+	l.mu.Lock()
+	r := l.ride(rideID)
+	a := r.accept(d.ID)
+	if a == nil {
+		// Réponse sans AcceptRide de ce driver
+		a = &ledgerAccept{DriverID: d.ID, Name: d.Name}
+		r.accepts = append(r.accepts, a)
+	}
+	a.Responses = append(a.Responses, code)
+	l.mu.Unlock()
+}
+
+// released : Le driver rend la course, qui peut être attribuée à un autre
+func (l *RideLedger) released(d *Driver, rideID int64) {
+	l.mu.Lock()
+	if a := l.ride(rideID).accept(d.ID); a != nil {
+		a.Released = true
+	}
+	l.mu.Unlock()
+}
+
+// Report : Courses attribuées plusieurs fois ou jamais
+func (l *RideLedger) Report(w io.Writer) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reportTitle(w, "Ride assignment ledger")
+
+	var multiple, none, pending, expired []*ledgerRide
+	unattempted := 0
+	contention := 0
+	for _, id := range l.order {
+		r := l.rides[id]
+		contention += len(r.accepts)
+		switch n := len(r.winners()); {
+		case n > 1:
+			multiple = append(multiple, r)
+		case n == 0 && r.released():
+			// Redispatch suivi par les annulations
+		case n == 0 && r.answered():
+			none = append(none, r)
+			if len(r.accepts) == 0 {
+				unattempted++
+			} else if r.expiredOnly() {
+				expired = append(expired, r)
+			}
+		case n == 0:
+			pending = append(pending, r)
+		}
+	}
+
+	fmt.Fprintf(w, "Rides: %d, AcceptRide sent: %d", len(l.order), contention)
+	if len(l.order) > 0 {
+		fmt.Fprintf(w, " (%.2f drivers per ride)", float64(contention)/float64(len(l.order)))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%s won by more than one driver: %d\n", passFail(len(multiple) == 0), len(multiple))
+	for _, r := range multiple {
+		fmt.Fprintf(w, "  ride %d:", r.ID)
+		for _, a := range r.winners() {
+			fmt.Fprintf(w, " %s (%d)", a.Name, a.DriverID)
+		}
+		fmt.Fprintln(w)
+	}
+	// Une course que personne n'a acceptée peut venir des refus simulés, et le serveur
+	// a le droit de refuser un AcceptRide expiré : seules les courses acceptées dans
+	// les temps sans gagnant sont des échecs du serveur
+	attempted := len(none) - unattempted - len(expired)
+	fmt.Fprintf(w, "%s won by nobody: %d (%d with AcceptRide in time, %d only expired, %d never accepted)\n",
+		passFail(attempted == 0), len(none), attempted, len(expired), unattempted)
+	for _, r := range none {
+		if len(r.accepts) == 0 || r.expiredOnly() {
+			continue
+		}
+		fmt.Fprintf(w, "  ride %d:", r.ID)
+		for _, a := range r.accepts {
+			fmt.Fprintf(w, " %s (%d) %v", a.Name, a.DriverID, a.Responses)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "Accepted only after expiry (information): %d\n", len(expired))
+	for i, r := range expired {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(expired)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %d:", r.ID)
+		for _, a := range r.accepts {
+			fmt.Fprintf(w, " %s (%d) %v", a.Name, a.DriverID, a.Responses)
+		}
+		fmt.Fprintln(w)
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	fmt.Fprintf(w, "Waiting for AcceptRideResponse: %d\n", len(pending))
+	for i, r := range pending {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(pending)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %d: %d accepts\n", r.ID, len(r.accepts))
+	}
+
+	return len(multiple) == 0 && attempted == 0
+}
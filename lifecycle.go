@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"bench_dispatch/datamodels"
+)
+
+// ridePhase : Etape du cycle de vie d'une course
+type ridePhase int
+
+// Etapes d'une course, dans l'ordre
+const (
+	phaseCreateRide ridePhase = iota
+	phaseNewRide
+	phaseAcceptRide
+	phaseAcceptRideResponse
+	phaseApproach
+	phasePickUpPassenger
+	phasePendingPayment
+	phasePendingPaymentResponse
+	phaseEnded
+	nbPhases
+)
+
+var phaseNames = [nbPhases]string{
+	"CreateRide",
+	"NewRide",
+	"AcceptRide",
+	"AcceptRideResponse",
+	"Approach",
+	"PickUpPassenger",
+	"PendingPayment",
+	"PendingPaymentResponse",
+	"Ended",
+}
+
+// phaseByRideState : Etape correspondant à un ChangeRideState envoyé par le driver
+var phaseByRideState = map[datamodels.RideState]ridePhase{
+	datamodels.Approach:        phaseApproach,
+	datamodels.PickUpPassenger: phasePickUpPassenger,
+	datamodels.PendingPayment:  phasePendingPayment,
+	datamodels.Ended:           phaseEnded,
+}
+
+// phaseSpan : Durée agrégée entre deux étapes
+type phaseSpan struct {
+	Name     string
+	From, To ridePhase
+}
+
+var phaseSpans = []phaseSpan{
+	{"Time to dispatch", phaseCreateRide, phaseNewRide},
+	{"Offer to accept", phaseNewRide, phaseAcceptRide},
+	{"Accept confirmation", phaseAcceptRide, phaseAcceptRideResponse},
+	{"Approach", phaseApproach, phasePickUpPassenger},
+	{"Trip", phasePickUpPassenger, phasePendingPayment},
+	{"Payment round trip", phasePendingPayment, phasePendingPaymentResponse},
+	{"Create to end", phaseCreateRide, phaseEnded},
+}
+
+// rideTimeline : Horodatage de chaque étape d'une course
+type rideTimeline struct {
+	ExternalID string
+	ID         int64
+	BookerID   int
+	DriverID   int
+	At         [nbPhases]time.Time
+}
+
+// last : Dernière étape atteinte
+func (t *rideTimeline) last() ridePhase {
+	for p := nbPhases - 1; p >= 0; p-- {
+		if !t.At[p].IsZero() {
+			return p
+		}
+	}
+	return -1
+}
+
+// RideTimelines : Cycle de vie de chaque course, tous drivers et bookers confondus
+type RideTimelines struct {
+	mu    sync.Mutex
+	rides map[string]*rideTimeline
+	ids   map[int64]string
+	order []string
+}
+
+// NewRideTimelines : Creation du suivi des courses
+func NewRideTimelines() *RideTimelines {
+	return &RideTimelines{
+		rides: make(map[string]*rideTimeline),
+		ids:   make(map[int64]string),
+	}
+}
+
+// timeline : Retrouve ou crée la course. L'appelant doit détenir t.mu.
+func (t *RideTimelines) timeline(id int64, externalID string) *rideTimeline {
+	if externalID == "" {
+		if ext, has := t.ids[id]; has {
+			externalID = ext
+		} else {
+			externalID = fmt.Sprintf("#%d", id)
+		}
+	}
+
+	r, has := t.rides[externalID]
+	if !has {
+		r = &rideTimeline{ExternalID: externalID}
+		t.rides[externalID] = r
+		t.order = append(t.order, externalID)
+	}
+	if id != 0 && r.ID == 0 {
+		r.ID = id
+		t.ids[id] = externalID
+	}
+	return r
+}
+
+// created : CreateRide envoyé par un booker
+func (t *RideTimelines) created(booker *Driver, externalID string, at time.Time) {
+	t.mu.Lock()
+	r := t.timeline(0, externalID)
+	r.BookerID = booker.ID
+	r.At[phaseCreateRide] = at
+	t.mu.Unlock()
+}
+
+// mark : Horodate une étape de la course, seule la première occurrence est conservée
+func (t *RideTimelines) mark(ride datamodels.RideData, phase ridePhase, driverID int, at time.Time) {
+	if ride.ID == 0 && ride.ExternalID == "" {
+		return
+	}
+
+	t.mu.Lock()
+	r := t.timeline(ride.ID, ride.ExternalID)
+	if r.At[phase].IsZero() {
+		r.At[phase] = at
+	}
+	if phase >= phaseAcceptRideResponse && r.DriverID == 0 {
+		r.DriverID = driverID
+	}
+	t.mu.Unlock()
+}
+
+// externalID : ID externe d'une course, retrouvé par son ID serveur si besoin
+func (t *RideTimelines) externalID(ride datamodels.RideData) string {
+	if ride.ExternalID != "" {
+		return ride.ExternalID
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ids[ride.ID]
+}
+
+// booking : ID serveur, driver attribué et prise en charge effectuée d'une course
+func (t *RideTimelines) booking(externalID string) (int64, int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, has := t.rides[externalID]
+	if !has {
+		return 0, 0, false
+	}
+	return r.ID, r.DriverID, !r.At[phasePickUpPassenger].IsZero()
+}
+
+// span : Durée entre deux étapes d'une course
+func (t *RideTimelines) span(externalID string, from, to ridePhase) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, has := t.rides[externalID]
+	if !has || r.At[from].IsZero() || r.At[to].IsZero() {
+		return 0, false
+	}
+	return r.At[to].Sub(r.At[from]), true
+}
+
+// createdAt : Date d'envoi du CreateRide d'une course
+func (t *RideTimelines) createdAt(ride datamodels.RideData) (time.Time, bool) {
+	ext := t.externalID(ride)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, has := t.rides[ext]
+	if !has || r.At[phaseCreateRide].IsZero() {
+		return time.Time{}, false
+	}
+	return r.At[phaseCreateRide], true
+}
+
+// Report : Durées agrégées par phase et courses inachevées
+func (t *RideTimelines) Report(w io.Writer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reportTitle(w, "Ride lifecycle")
+
+	spans := make([]durations, len(phaseSpans))
+	stopped := make(map[ridePhase]int)
+	for _, ext := range t.order {
+		r := t.rides[ext]
+		for i, s := range phaseSpans {
+			if !r.At[s.From].IsZero() && !r.At[s.To].IsZero() {
+				spans[i] = append(spans[i], r.At[s.To].Sub(r.At[s.From]))
+			}
+		}
+		if last := r.last(); last != phaseEnded {
+			stopped[last]++
+		}
+	}
+
+	fmt.Fprintf(w, "Rides: %d, ended: %d\n", len(t.order), len(t.order)-sumCounts(stopped))
+	for i, s := range phaseSpans {
+		fmt.Fprintf(w, "  %-20s %s\n", s.Name, spans[i].summary())
+	}
+	for p := ridePhase(0); p < phaseEnded; p++ {
+		if n := stopped[p]; n > 0 {
+			fmt.Fprintf(w, "  stopped after %-22s : %d\n", phaseNames[p], n)
+		}
+	}
+	return true
+}
+
+func sumCounts(m map[ridePhase]int) int {
+	n := 0
+	for _, c := range m {
+		n += c
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"bench_dispatch/datamodels"
+)
+
+// LoginStats : Comptage des logins réussis et refusés par code d'erreur
+type LoginStats struct {
+	mu       sync.Mutex
+	success  int
+	retries  int
+	timeouts int // Login sans LoginResponse dans le délai
+	failures map[int]int
+	gaveUp   map[int]string
+}
+
+// NewLoginStats : Creation des statistiques de login
+func NewLoginStats() *LoginStats {
+	return &LoginStats{
+		failures: make(map[int]int),
+		gaveUp:   make(map[int]string),
+	}
+}
+
+func (l *LoginStats) succeeded(attempt int) {
+	l.mu.Lock()
+	l.success++
+	if attempt > 1 {
+		l.retries++
+	}
+	l.mu.Unlock()
+}
+
+func (l *LoginStats) failed(code int) {
+	l.mu.Lock()
+	l.failures[code]++
+	l.mu.Unlock()
+}
+
+func (l *LoginStats) timedOut() {
+	l.mu.Lock()
+	l.timeouts++
+	l.mu.Unlock()
+}
+
+func (l *LoginStats) abandon(d *Driver) {
+	l.mu.Lock()
+	l.gaveUp[d.ID] = d.Name
+	l.mu.Unlock()
+}
+
+// Report : Logins réussis, échecs par code et drivers jamais connectés
+func (l *LoginStats) Report(w io.Writer) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reportTitle(w, "Login")
+	fmt.Fprintf(w, "Succeeded: %d (%d after retry)\n", l.success, l.retries)
+
+	codes := make([]int, 0, len(l.failures))
+	for code := range l.failures {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		e := datamodels.ErrorFromCode(code)
+		fmt.Fprintf(w, "Failed   : %d x [%d] %s\n", l.failures[code], e.ID, e.Message)
+	}
+
+	if l.timeouts > 0 {
+		fmt.Fprintf(w, "Timed out: %d x no LoginResponse within %d BT\n", l.timeouts, conf.LoginPolicy.Timeout)
+	}
+
+	ids := make([]int, 0, len(l.gaveUp))
+	for id := range l.gaveUp {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		fmt.Fprintf(w, "Never logged in: %s (%d)\n", l.gaveUp[id], id)
+	}
+
+	return len(l.gaveUp) == 0
+}
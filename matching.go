@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"bench_dispatch/datamodels"
+)
+
+// canServe : Le véhicule du driver satisfait les critères de recherche de la course
+func (d *Driver) canServe(search datamodels.SearchOptions) bool {
+	if search.VehicleType != 0 && search.VehicleType != d.VehicleType {
+		return false
+	}
+	for _, wanted := range search.VehicleOptions {
+		found := false
+		for _, opt := range d.VehicleOptions {
+			if opt == wanted {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func vehicleTypeName(t datamodels.VehicleType) string {
+	for name, vt := range datamodels.VehicleTypes {
+		if vt == t {
+			return name
+		}
+	}
+	if t == 0 {
+		return "Any"
+	}
+	return fmt.Sprintf("Type(%d)", t)
+}
+
+func vehicleOptionNames(opts []datamodels.VehicleOption) string {
+	names := make([]string, 0, len(opts))
+	for _, o := range opts {
+		name := fmt.Sprintf("Option(%d)", o)
+		for n, vo := range datamodels.VehicleOptions {
+			if vo == o {
+				name = n
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return "[" + strings.Join(names, ",") + "]"
+}
+
+// matchRide : Critères d'une course créée par le bench
+type matchRide struct {
+	ExternalID string
+	Search     datamodels.SearchOptions
+	Capable    int  // Drivers du parc capables de servir la course à sa création
+	Offered    bool // NewRide reçu par au moins un driver capable
+}
+
+// mismatch : NewRide reçu par un driver incapable de servir la course
+type mismatch struct {
+	RideID   int64
+	DriverID int
+	Name     string
+	Group    string
+	Type     datamodels.VehicleType
+	Options  []datamodels.VehicleOption
+	Wanted   datamodels.SearchOptions
+}
+
+// VehicleMatching : Vérifie que le serveur ne propose une course qu'aux véhicules compatibles
+type VehicleMatching struct {
+	mu         sync.Mutex
+	rides      map[string]*matchRide
+	order      []string
+	mismatches []mismatch
+}
+
+// NewVehicleMatching : Creation du contrôle type / options de véhicule
+func NewVehicleMatching() *VehicleMatching {
+	return &VehicleMatching{
+		rides: make(map[string]*matchRide),
+	}
+}
+
+// created : Enregistre les critères de la course et le nombre de véhicules capables
+func (m *VehicleMatching) created(h *Hub, create datamodels.CreateRide) {
+	r := &matchRide{ExternalID: create.Ride.ExternalID, Search: create.SearchOptions}
+
+	h.mu.RLock()
+	for _, d := range h.drivers {
+		if d.canServe(create.SearchOptions) && d.canCarry(create.Ride) {
+			r.Capable++
+		}
+	}
+	h.mu.RUnlock()
+
+	m.mu.Lock()
+	m.rides[r.ExternalID] = r
+	m.order = append(m.order, r.ExternalID)
+	m.mu.Unlock()
+}
+
+// offered : Vérifie qu'un NewRide reçu correspond au véhicule du driver
+func (m *VehicleMatching) offered(d *Driver, create datamodels.CreateRide) {
+	capable := d.canServe(create.SearchOptions)
+	ext := d.hub.timelines.externalID(create.Ride)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !capable {
+		m.mismatches = append(m.mismatches, mismatch{
+			RideID:   create.Ride.ID,
+			DriverID: d.ID,
+			Name:     d.Name,
+			Group:    d.Group.Name,
+			Type:     d.VehicleType,
+			Options:  d.VehicleOptions,
+			Wanted:   create.SearchOptions,
+		})
+		return
+	}
+	if r, has := m.rides[ext]; has && d.canCarry(create.Ride) {
+		r.Offered = true
+	}
+}
+
+// Report : Offres à des véhicules incompatibles et courses jamais proposées à un véhicule capable
+func (m *VehicleMatching) Report(w io.Writer) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reportTitle(w, "Vehicle type and options matching")
+
+	fmt.Fprintf(w, "%s offers to incapable vehicles: %d\n", passFail(len(m.mismatches) == 0), len(m.mismatches))
+	for i, mm := range m.mismatches {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(m.mismatches)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %d wants %s %s, offered to %s (%d) group %s: %s %s\n", mm.RideID,
+			vehicleTypeName(mm.Wanted.VehicleType), vehicleOptionNames(mm.Wanted.VehicleOptions),
+			mm.Name, mm.DriverID, mm.Group, vehicleTypeName(mm.Type), vehicleOptionNames(mm.Options))
+	}
+
+	var missed []*matchRide
+	noCapable := 0
+	for _, ext := range m.order {
+		r := m.rides[ext]
+		switch {
+		case r.Capable == 0:
+			noCapable++
+		case !r.Offered:
+			missed = append(missed, r)
+		}
+	}
+	fmt.Fprintf(w, "%s rides never offered to a capable vehicle: %d\n", passFail(len(missed) == 0), len(missed))
+	for i, r := range missed {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(missed)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %s wants %s %s, %d capable vehicles\n", r.ExternalID,
+			vehicleTypeName(r.Search.VehicleType), vehicleOptionNames(r.Search.VehicleOptions), r.Capable)
+	}
+	fmt.Fprintf(w, "Rides no vehicle of the fleet can serve: %d\n", noCapable)
+
+	return len(m.mismatches) == 0 && len(missed) == 0
+}
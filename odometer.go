@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// driftBounds : Tranches des histogrammes d'écart en %
+var driftBounds = []float64{-50, -20, -10, -5, -2, 2, 5, 10, 20, 50}
+
+// moveTowards : Avance le driver d'un BT vers target, ToDest étant la distance restante.
+// Retourne la distance parcourue en km.
+func (d *Driver) moveTowards(target datamodels.Coordinates) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	step := d.stepKm()
+	if d.ToDest > 0 {
+		f := math.Min(1, step/d.ToDest)
+		d.Coord.Latitude += (target.Latitude - d.Coord.Latitude) * f
+		d.Coord.Longitude += (target.Longitude - d.Coord.Longitude) * f
+	}
+	return step
+}
+
+// odometer : Ajoute au compteur de la course la distance depuis la dernière position envoyée.
+// L'appelant doit détenir d.mu.
+func (m *rideMeter) odometer(sent datamodels.Coordinates) {
+	if m.PickedUp.IsZero() || !m.DroppedOff.IsZero() {
+		return
+	}
+	m.Sent += geoloc.DistanceAccurate(m.last.Latitude, m.last.Longitude, sent.Latitude, sent.Longitude) / 1000
+	m.last = sent
+}
+
+// drift : Ecart relatif en % entre la valeur du serveur et celle du bench
+func drift(server, bench float64) float64 {
+	if bench == 0 {
+		return 0
+	}
+	return 100 * (server - bench) / bench
+}
+
+// rideDistance : Distance et durée d'une course selon le bench et le serveur
+type rideDistance struct {
+	ExternalID string
+	DriverID   int
+	Km         float64 // Distance simulée
+	SentKm     float64 // Distance parcourue entre les positions envoyées
+	Min        float64
+	ServerKm   float64
+	ServerMin  float64
+}
+
+func (r rideDistance) kmDrift() float64  { return drift(r.ServerKm, r.SentKm) }
+func (r rideDistance) minDrift() float64 { return drift(r.ServerMin, r.Min) }
+
+// RideDistances : Compare les km / min du serveur avec la course simulée
+type RideDistances struct {
+	mu    sync.Mutex
+	rides []rideDistance
+}
+
+// NewRideDistances : Creation de la comparaison des compteurs
+func NewRideDistances() *RideDistances {
+	return &RideDistances{}
+}
+
+// billed : Statistiques du PendingPaymentResponse d'une course terminée
+func (r *RideDistances) billed(externalID string, d *Driver, m rideMeter, resp datamodels.PendingPaymentResponse) {
+	stats := paymentStats(resp)
+	rd := rideDistance{
+		ExternalID: externalID,
+		DriverID:   d.ID,
+		Km:         m.Km,
+		SentKm:     m.Sent,
+		Min:        m.minutes(),
+		ServerKm:   statValue(stats, statKm),
+		ServerMin:  statValue(stats, statMin),
+	}
+
+	r.mu.Lock()
+	r.rides = append(r.rides, rd)
+	r.mu.Unlock()
+}
+
+// Report : Histogrammes des écarts et pires courses
+func (r *RideDistances) Report(w io.Writer) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reportTitle(w, "Distance and duration")
+
+	var kms, mins, routes samples
+	for _, rd := range r.rides {
+		kms = append(kms, rd.kmDrift())
+		mins = append(mins, rd.minDrift())
+		routes = append(routes, drift(rd.SentKm, rd.Km))
+	}
+
+	fmt.Fprintf(w, "Rides compared: %d\n", len(r.rides))
+	fmt.Fprintf(w, "  Sent positions vs route km %s\n", routes.summary("%"))
+	fmt.Fprintf(w, "  Server km vs sent km       %s\n", kms.summary("%"))
+	kms.histogram(w, driftBounds, "%")
+	fmt.Fprintf(w, "  Server min vs ride min     %s\n", mins.summary("%"))
+	mins.histogram(w, driftBounds, "%")
+
+	ok := true
+	ok = r.worst(w, "km", conf.Thresholds.MaxKmDrift, rideDistance.kmDrift) && ok
+	ok = r.worst(w, "min", conf.Thresholds.MaxMinDrift, rideDistance.minDrift) && ok
+	return ok
+}
+
+// worst : Courses les plus éloignées du bench, échec au delà de max % si max > 0
+func (r *RideDistances) worst(w io.Writer, unit string, max float64, gap func(rideDistance) float64) bool {
+	rides := append([]rideDistance{}, r.rides...)
+	sort.Slice(rides, func(i, j int) bool { return math.Abs(gap(rides[i])) > math.Abs(gap(rides[j])) })
+
+	beyond := 0
+	for _, rd := range rides {
+		if max > 0 && math.Abs(gap(rd)) > max {
+			beyond++
+		}
+	}
+	if max > 0 {
+		fmt.Fprintf(w, "%s %s drift beyond %.0f%%: %d\n", passFail(beyond == 0), unit, max, beyond)
+	}
+
+	fmt.Fprintf(w, "  Worst %s drifts:\n", unit)
+	for i, rd := range rides {
+		if i == 10 {
+			break
+		}
+		fmt.Fprintf(w, "    ride %s driver %d: route %.2f km, sent %.2f km, server %.2f km, %.1f min, server %.1f min (%+.1f%%)\n",
+			rd.ExternalID, rd.DriverID, rd.Km, rd.SentKm, rd.ServerKm, rd.Min, rd.ServerMin, gap(rd))
+	}
+	return beyond == 0
+}
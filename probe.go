@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	"bench_dispatch/datamodels"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+var errProbeTimeout = errors.New("no response from server")
+
+// probeTimeout : Attente max d'une réponse du serveur dans les modes de test
+func probeTimeout() time.Duration {
+	if conf.Bench.ProbeTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(conf.Bench.ProbeTimeout) * time.Second
+}
+
+// probeConn : Connexion dédiée aux tests protocolaires, hors simulation.
+// Les échanges sont synchrones : une requête, puis attente de la réponse.
+type probeConn struct {
+	conn  net.Conn
+	reqID int
+}
+
+func dialProbe(u url.URL) (*probeConn, error) {
+	conn, _, _, err := ws.DefaultDialer.Dial(context.Background(), u.String())
+	if err != nil {
+		return nil, err
+	}
+	return &probeConn{conn: conn}, nil
+}
+
+// send : Envoie une requête et retourne son ID
+func (p *probeConn) send(method string, params datamodels.DataParams) (int, error) {
+	p.reqID++
+	payload, err := json.Marshal(datamodels.Request{
+		ID:     p.reqID,
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return p.reqID, err
+	}
+	return p.reqID, p.sendRaw(payload)
+}
+
+// sendRaw : Envoie une trame texte brute
+func (p *probeConn) sendRaw(payload []byte) error {
+	p.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	return wsutil.WriteClientText(p.conn, payload)
+}
+
+// expect : Attend la réponse à la requête reqID ou un message de l'une des méthodes données.
+// Les autres messages (NewRide, ...) sont ignorés.
+func (p *probeConn) expect(reqID int, timeout time.Duration, methods ...string) (*datamodels.Response, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		p.conn.SetReadDeadline(deadline)
+		payload, op, err := wsutil.ReadServerData(p.conn)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return nil, errProbeTimeout
+			}
+			return nil, err
+		}
+		if op != ws.OpText {
+			continue
+		}
+
+		resp := &datamodels.Response{}
+		if err := json.Unmarshal(payload, resp); err != nil {
+			return nil, err
+		}
+		if resp.ID == reqID && resp.Method != "NewRide" {
+			return resp, nil
+		}
+		for _, m := range methods {
+			if resp.Method == m {
+				return resp, nil
+			}
+		}
+	}
+}
+
+// call : Envoie une requête et attend sa réponse
+func (p *probeConn) call(method string, params datamodels.DataParams, timeout time.Duration, methods ...string) (*datamodels.Response, error) {
+	id, err := p.send(method, params)
+	if err != nil {
+		return nil, err
+	}
+	return p.expect(id, timeout, methods...)
+}
+
+func (p *probeConn) close() {
+	ws.WriteFrame(p.conn, ws.MaskFrame(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusNormalClosure, "Probe done"))))
+	p.conn.Close()
+}
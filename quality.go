@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// candidate : Driver libre et compatible au moment du CreateRide
+type candidate struct {
+	DriverID int
+	Distance float64 // Distance en mètres entre la dernière position envoyée et la prise en charge
+}
+
+// qualityRide : Candidats d'une course et driver retenu par le serveur
+type qualityRide struct {
+	candidates []candidate // Triés par distance croissante
+	assigned   bool
+	winnerID   int
+}
+
+// DispatchQuality : Le driver retenu était-il le plus proche des drivers éligibles ?
+type DispatchQuality struct {
+	mu    sync.Mutex
+	rides map[string]*qualityRide
+}
+
+// NewDispatchQuality : Creation du score de dispatch
+func NewDispatchQuality() *DispatchQuality {
+	return &DispatchQuality{
+		rides: make(map[string]*qualityRide),
+	}
+}
+
+// snapshot : Relève les drivers éligibles au moment de la création de la course.
+// Seuls les drivers ayant déjà envoyé une position à l'heure sont candidats : le serveur
+// ne peut pas placer les autres.
+func (q *DispatchQuality) snapshot(h *Hub, booker *Driver, create datamodels.CreateRide) {
+	pickup := create.Ride.FromAddress.Coord
+	var cands []candidate
+
+	// L'index peut avoir un temps de retard sur LastSent, la distance est recalculée
+	for _, d := range h.nearby(pickup, conf.Dispatch.Radius) {
+		if d == booker {
+			continue
+		}
+		d.mu.RLock()
+		located := d.LastSent.Latitude != 0 || d.LastSent.Longitude != 0
+		if located && d.DriverState == datamodels.Free && d.canServe(create.SearchOptions) && d.canCarry(create.Ride) {
+			cands = append(cands, candidate{
+				DriverID: d.ID,
+				Distance: geoloc.DistanceAccurate(d.LastSent.Latitude, d.LastSent.Longitude, pickup.Latitude, pickup.Longitude),
+			})
+		}
+		d.mu.RUnlock()
+	}
+	sort.Slice(cands, func(i, j int) bool { return cands[i].Distance < cands[j].Distance })
+
+	q.mu.Lock()
+	q.rides[create.Ride.ExternalID] = &qualityRide{candidates: cands}
+	q.mu.Unlock()
+}
+
+// assigned : Enregistre le driver retenu pour la course
+func (q *DispatchQuality) assigned(externalID string, d *Driver) {
+	q.mu.Lock()
+	if r, has := q.rides[externalID]; has && !r.assigned {
+		r.assigned = true
+		r.winnerID = d.ID
+	}
+	q.mu.Unlock()
+}
+
+// score : Rang du driver retenu (1 pour le plus proche, 0 s'il n'était pas éligible)
+// et distance supplémentaire par rapport au plus proche
+func (r *qualityRide) score() (int, float64) {
+	for i, c := range r.candidates {
+		if c.DriverID == r.winnerID {
+			return i + 1, c.Distance - r.candidates[0].Distance
+		}
+	}
+	return 0, 0
+}
+
+// rank : Rang du driver retenu pour une course attribuée à un driver éligible
+func (q *DispatchQuality) rank(externalID string) (int, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r, has := q.rides[externalID]
+	if !has || !r.assigned {
+		return 0, false
+	}
+	rank, _ := r.score()
+	return rank, rank > 0
+}
+
+// Report : Rang et distance supplémentaire du driver retenu
+func (q *DispatchQuality) Report(w io.Writer) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	reportTitle(w, "Dispatch quality")
+	if conf.Dispatch.Radius > 0 {
+		fmt.Fprintf(w, "Candidates: free drivers within %.0fm of their last position sent, drivers that never sent one are left out\n", conf.Dispatch.Radius)
+	}
+
+	var excess, ranks samples
+	scored, nearest, notEligible, noCandidate := 0, 0, 0, 0
+	for _, r := range q.rides {
+		if !r.assigned {
+			continue
+		}
+		if len(r.candidates) == 0 {
+			noCandidate++
+			continue
+		}
+		rank, extra := r.score()
+		if rank == 0 {
+			notEligible++
+			continue
+		}
+		scored++
+		if rank == 1 {
+			nearest++
+		}
+		ranks = append(ranks, float64(rank))
+		excess = append(excess, extra)
+	}
+
+	fmt.Fprintf(w, "Rides scored: %d\n", scored)
+	if scored > 0 {
+		fmt.Fprintf(w, "  Nearest driver chosen %.1f%%\n", 100*float64(nearest)/float64(scored))
+	}
+	fmt.Fprintf(w, "  Winner rank           %s\n", ranks.summary(""))
+	fmt.Fprintf(w, "  Excess pickup distance %s\n", excess.summary("m"))
+	fmt.Fprintf(w, "  Winner not eligible at creation: %d\n", notEligible)
+	fmt.Fprintf(w, "  No eligible driver at creation : %d\n", noCandidate)
+	return true
+}
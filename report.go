@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bench_dispatch/clog"
+)
+
+// Reporter : Section du rapport de fin de bench
+// Report retourne false si la section contient des échecs
+type Reporter interface {
+	Report(w io.Writer) bool
+}
+
+var (
+	reportMu  sync.Mutex
+	reporters []Reporter
+)
+
+// addReporter : Ajoute une section au rapport
+func addReporter(r Reporter) {
+	reportMu.Lock()
+	reporters = append(reporters, r)
+	reportMu.Unlock()
+}
+
+// writeReport : Ecrit le rapport complet et retourne le code de sortie du bench
+func writeReport() int {
+	var w io.Writer = os.Stdout
+
+	if conf.Report.File != "" {
+		f, err := os.Create(conf.Report.File)
+		if err != nil {
+			clog.Error("main", "Report", "%s", err)
+		} else {
+			defer f.Close()
+			w = f
+		}
+	}
+
+	fmt.Fprintf(w, "Bench dispatch report - %s\n", time.Now().Format(time.RFC3339))
+
+	status := 0
+	reportMu.Lock()
+	for _, r := range reporters {
+		fmt.Fprintln(w)
+		if !r.Report(w) {
+			status = 1
+		}
+	}
+	reportMu.Unlock()
+
+	if status == 0 {
+		fmt.Fprintf(w, "\nRESULT: PASS\n")
+	} else {
+		fmt.Fprintf(w, "\nRESULT: FAIL\n")
+	}
+	return status
+}
+
+// reportTitle : Entête d'une section du rapport
+func reportTitle(w io.Writer, title string) {
+	fmt.Fprintf(w, "== %s ==\n", title)
+}
+
+// passFail : Libellé d'un résultat
+func passFail(ok bool) string {
+	if ok {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
+// durations : Echantillon de durées pour les statistiques du rapport
+type durations []time.Duration
+
+func (d durations) percentile(p int) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	s := append(durations{}, d...)
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[(len(s)-1)*p/100]
+}
+
+func (d durations) mean() time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, v := range d {
+		sum += v
+	}
+	return sum / time.Duration(len(d))
+}
+
+// summary : count / moyenne / médiane / p95 / max
+func (d durations) summary() string {
+	return fmt.Sprintf("n=%-5d avg %-9s p50 %-9s p95 %-9s max %s", len(d),
+		d.mean().Round(time.Millisecond), d.percentile(50).Round(time.Millisecond),
+		d.percentile(95).Round(time.Millisecond), d.percentile(100).Round(time.Millisecond))
+}
+
+// samples : Echantillon de valeurs pour les statistiques du rapport
+type samples []float64
+
+func (s samples) percentile(p int) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	c := append(samples{}, s...)
+	sort.Float64s(c)
+	return c[(len(c)-1)*p/100]
+}
+
+func (s samples) mean() float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range s {
+		sum += v
+	}
+	return sum / float64(len(s))
+}
+
+// summary : count / moyenne / médiane / p95 / max avec l'unité donnée
+func (s samples) summary(unit string) string {
+	return fmt.Sprintf("n=%-5d avg %.2f%s p50 %.2f%s p95 %.2f%s max %.2f%s", len(s),
+		s.mean(), unit, s.percentile(50), unit, s.percentile(95), unit, s.percentile(100), unit)
+}
+
+// histogram : Répartition des valeurs dans les tranches bornées par bounds, une ligne par tranche
+func (s samples) histogram(w io.Writer, bounds []float64, unit string) {
+	if len(s) == 0 {
+		return
+	}
+	counts := make([]int, len(bounds)+1)
+	for _, v := range s {
+		i := sort.SearchFloat64s(bounds, v)
+		if i < len(bounds) && v == bounds[i] {
+			i++
+		}
+		counts[i]++
+	}
+
+	for i, n := range counts {
+		var label string
+		switch {
+		case i == 0:
+			label = fmt.Sprintf("< %g%s", bounds[0], unit)
+		case i == len(bounds):
+			label = fmt.Sprintf(">= %g%s", bounds[i-1], unit)
+		default:
+			label = fmt.Sprintf("%g .. %g%s", bounds[i-1], bounds[i], unit)
+		}
+		bar := strings.Repeat("#", (n*40+len(s)-1)/len(s))
+		fmt.Fprintf(w, "    %-16s %5d %s\n", label, n, bar)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// scheduleDelay : Délai avant le départ d'une course réservée, 0 pour une course immédiate
+func scheduleDelay() time.Duration {
+	rc := conf.RideConfig
+	if rc.ScheduledPercent <= 0 || rand.Intn(100) >= rc.ScheduledPercent {
+		return 0
+	}
+	delay := rc.ScheduleMinDelay
+	if rc.ScheduleMaxDelay > delay {
+		delay += rand.Intn(rc.ScheduleMaxDelay - delay + 1)
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// scheduledRide : Course réservée et premier NewRide reçu
+type scheduledRide struct {
+	ExternalID string
+	Start      time.Time
+	Notified   time.Time
+	Cancelled  bool // Annulée par le client, jamais comptée comme manquée
+}
+
+// lead : Avance du premier NewRide sur la date de départ
+func (r *scheduledRide) lead() time.Duration {
+	return r.Start.Sub(r.Notified)
+}
+
+// ScheduledRides : Vérifie que les réservations sont dispatchées dans la fenêtre précédant le départ
+type ScheduledRides struct {
+	mu    sync.Mutex
+	rides map[string]*scheduledRide
+	order []string
+}
+
+// NewScheduledRides : Creation du suivi des réservations
+func NewScheduledRides() *ScheduledRides {
+	return &ScheduledRides{
+		rides: make(map[string]*scheduledRide),
+	}
+}
+
+// created : Enregistre la date de départ d'une course réservée
+func (s *ScheduledRides) created(externalID string, start time.Time) {
+	s.mu.Lock()
+	s.rides[externalID] = &scheduledRide{ExternalID: externalID, Start: start}
+	s.order = append(s.order, externalID)
+	s.mu.Unlock()
+}
+
+// notified : NewRide reçu par un driver, seul le premier compte
+func (s *ScheduledRides) notified(externalID string, at time.Time) {
+	s.mu.Lock()
+	if r, has := s.rides[externalID]; has && r.Notified.IsZero() {
+		r.Notified = at
+	}
+	s.mu.Unlock()
+}
+
+// cancelled : Course annulée par le client, elle n'a plus à être dispatchée
+func (s *ScheduledRides) cancelled(externalID string) {
+	s.mu.Lock()
+	if r, has := s.rides[externalID]; has {
+		r.Cancelled = true
+	}
+	s.mu.Unlock()
+}
+
+// window : Bornes de la fenêtre de dispatch, en avance sur le départ
+func (s *ScheduledRides) window() (time.Duration, time.Duration) {
+	tolerance := time.Duration(conf.RideConfig.DispatchTolerance) * time.Second
+	return -tolerance, time.Duration(conf.RideConfig.DispatchWindow)*time.Second + tolerance
+}
+
+// Report : Dispatch en avance, à l'heure, en retard ou jamais reçu
+func (s *ScheduledRides) Report(w io.Writer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reportTitle(w, "Scheduled rides")
+
+	minLead, maxLead := s.window()
+	now := time.Now()
+	var leads durations
+	var early, late []*scheduledRide
+	onTime, missed, pending, cancelled := 0, 0, 0, 0
+
+	for _, ext := range s.order {
+		r := s.rides[ext]
+		if r.Cancelled {
+			cancelled++
+		}
+		if r.Notified.IsZero() {
+			if r.Cancelled {
+				continue
+			}
+			if now.After(r.Start.Add(-minLead)) {
+				missed++
+			} else {
+				pending++
+			}
+			continue
+		}
+		lead := r.lead()
+		leads = append(leads, lead)
+		switch {
+		case lead > maxLead:
+			early = append(early, r)
+		case lead < minLead:
+			late = append(late, r)
+		default:
+			onTime++
+		}
+	}
+
+	fmt.Fprintf(w, "Scheduled rides: %d, expected NewRide between %s and %s before start\n", len(s.order), minLead, maxLead)
+	fmt.Fprintf(w, "  Lead before start %s\n", leads.summary())
+	fmt.Fprintf(w, "  On time: %d, not yet due: %d, cancelled by booker: %d\n", onTime, pending, cancelled)
+	fmt.Fprintf(w, "%s dispatched early: %d\n", passFail(len(early) == 0), len(early))
+	s.list(w, early)
+	fmt.Fprintf(w, "%s dispatched late: %d\n", passFail(len(late) == 0), len(late))
+	s.list(w, late)
+	fmt.Fprintf(w, "%s never dispatched after start: %d\n", passFail(missed == 0), missed)
+
+	return len(early) == 0 && len(late) == 0 && missed == 0
+}
+
+func (s *ScheduledRides) list(w io.Writer, rides []*scheduledRide) {
+	for i, r := range rides {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(rides)-i)
+			break
+		}
+		fmt.Fprintf(w, "  ride %s start %s, NewRide %s before\n", r.ExternalID, r.Start.Format("15:04:05"), r.lead().Round(time.Second))
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"bench_dispatch/clog"
+)
+
+// Disponibilité d'un driver selon le planning de son groupe
+const (
+	onDuty = iota
+	onBreak
+	offShift
+)
+
+const minutesPerDay = 24 * 60
+
+// parseShiftTime : "HH:MM" en minutes depuis minuit
+func parseShiftTime(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad shift time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// duty : Disponibilité des drivers du groupe à l'heure de simulation t.
+// Une fin de service avant le début passe minuit.
+func (g *driverGroup) duty(t time.Time) int {
+	if !g.shift {
+		return onDuty
+	}
+
+	since := t.Hour()*60 + t.Minute() - g.shiftStart
+	if since < 0 {
+		since += minutesPerDay
+	}
+	length := g.shiftEnd - g.shiftStart
+	if length <= 0 {
+		length += minutesPerDay
+	}
+	if since >= length {
+		return offShift
+	}
+	if g.BreakEvery > 0 && g.BreakDuration > 0 && since%(g.BreakEvery+g.BreakDuration) >= g.BreakEvery {
+		return onBreak
+	}
+	return onDuty
+}
+
+// logout : Fin de service, le driver ferme sa connexion
+func (d *Driver) logout() {
+	d.mu.Lock()
+	d.logged = false
+	d.online = false
+	d.mu.Unlock()
+
+	unlisten(d)
+	d.closeConnection()
+	d.hub.unlocated(d)
+	d.hub.shifts.record(d, "logout")
+}
+
+// reconnect : Début de service, nouvelle connexion et login
+func (d *Driver) reconnect() {
+	conn, err := dial(wsURL)
+	if err != nil {
+		clog.Warn("Driver", "Shift", "%s (%d) can't reconnect: %s", d.Name, d.ID, err)
+		return
+	}
+
+	d.io.Lock()
+	d.conn = Deadliner{conn, ioTimeout}
+	d.io.Unlock()
+	listen(d, conn)
+
+	d.mu.Lock()
+	d.online = true
+	d.loginAttempts = 0
+	d.mu.Unlock()
+
+	d.hub.shifts.record(d, "login")
+	d.login()
+}
+
+// shiftEvent : Changement de disponibilité d'un driver
+type shiftEvent struct {
+	At       time.Time // Heure de simulation
+	DriverID int
+	Group    string
+	Kind     string // login, logout, break, resume
+}
+
+// ShiftStats : Disponibilité du parc au fil de la journée simulée
+type ShiftStats struct {
+	mu            sync.Mutex
+	events        []shiftEvent
+	offersOnBreak int
+}
+
+// NewShiftStats : Creation du suivi des services
+func NewShiftStats() *ShiftStats {
+	return &ShiftStats{}
+}
+
+// record : Enregistre un changement de disponibilité à l'heure de simulation
+func (s *ShiftStats) record(d *Driver, kind string) {
+	e := shiftEvent{At: simNow(), DriverID: d.ID, Group: d.Group.Name, Kind: kind}
+
+	s.mu.Lock()
+	s.events = append(s.events, e)
+	s.mu.Unlock()
+
+	clog.File("SHIFT", d.Name, "%s %s", e.At.Format("15:04"), kind)
+}
+
+// offeredOnBreak : NewRide reçu par un driver en pause
+func (s *ShiftStats) offeredOnBreak() {
+	s.mu.Lock()
+	s.offersOnBreak++
+	s.mu.Unlock()
+}
+
+// Report : Prises et fins de service, pauses et drivers en service par heure simulée
+func (s *ShiftStats) Report(w io.Writer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reportTitle(w, "Shifts and breaks")
+
+	type counts struct{ login, logout, pause, resume int }
+	byGroup := make(map[string]*counts)
+	online := make(map[int]bool)
+	var hours []string
+	onlineByHour := make(map[string]int)
+
+	for _, e := range s.events {
+		c, has := byGroup[e.Group]
+		if !has {
+			c = &counts{}
+			byGroup[e.Group] = c
+		}
+		switch e.Kind {
+		case "login":
+			c.login++
+			online[e.DriverID] = true
+		case "logout":
+			c.logout++
+			delete(online, e.DriverID)
+		case "break":
+			c.pause++
+		case "resume":
+			c.resume++
+		}
+
+		hour := e.At.Format("Mon 15h")
+		if _, has := onlineByHour[hour]; !has {
+			hours = append(hours, hour)
+		}
+		onlineByHour[hour] = len(online)
+	}
+
+	groups := make([]string, 0, len(byGroup))
+	for g := range byGroup {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	for _, g := range groups {
+		c := byGroup[g]
+		fmt.Fprintf(w, "Group %s: %d logins, %d logouts, %d breaks, %d resumes\n", g, c.login, c.logout, c.pause, c.resume)
+	}
+	if len(hours) > 0 {
+		fmt.Fprintf(w, "Drivers logged in, by simulated hour:\n")
+		for _, h := range hours {
+			fmt.Fprintf(w, "  %s : %d\n", h, onlineByHour[h])
+		}
+	}
+
+	fmt.Fprintf(w, "%s NewRide received during a break: %d\n", passFail(s.offersOnBreak == 0), s.offersOnBreak)
+	return s.offersOnBreak == 0
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"bench_dispatch/clog"
+)
+
+// Horloge de simulation : démarre à Clock.Start et avance Clock.Speed fois plus vite que le temps réel
+var (
+	simStart  time.Time
+	realStart time.Time
+	simSpeed  = 1.0
+)
+
+// initClock : Démarre l'horloge de simulation
+func initClock() {
+	realStart = time.Now()
+	simStart = realStart
+	if conf.Clock.Speed > 0 {
+		simSpeed = conf.Clock.Speed
+	}
+
+	if conf.Clock.Start != "" {
+		t, err := time.ParseInLocation("15:04", conf.Clock.Start, time.Local)
+		if err != nil {
+			clog.Fatal("main", "Clock", fmt.Errorf("bad clock start %q: %s", conf.Clock.Start, err))
+		}
+		y, m, d := realStart.Date()
+		simStart = time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.Local)
+	}
+	clog.Info("main", "Clock", "Simulation starts at %s, speed x%.1f", simStart.Format("15:04"), simSpeed)
+}
+
+// simNow : Heure courante de la simulation
+func simNow() time.Time {
+	return simStart.Add(time.Duration(float64(time.Since(realStart)) * simSpeed))
+}
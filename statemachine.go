@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+)
+
+// trigger : Message reçu ou évènement interne à l'origine d'une transition
+type trigger struct {
+	Source string // Méthode du message reçu, ou "Life" pour la simulation
+	ReqID  int
+}
+
+var lifeTrigger = trigger{Source: "Life"}
+
+func responseTrigger(req *datamodels.Response) trigger {
+	return trigger{Source: req.Method, ReqID: req.ID}
+}
+
+func (t trigger) String() string {
+	if t.ReqID == 0 {
+		return t.Source
+	}
+	return fmt.Sprintf("%s #%d", t.Source, t.ReqID)
+}
+
+// noRide : Etat de course d'un driver sans course
+const noRide datamodels.RideState = 0
+
+// taximeterTransitions : Transitions autorisées de l'état du driver.
+// Rester dans le même état est toujours autorisé.
+var taximeterTransitions = map[datamodels.TaximeterState][]datamodels.TaximeterState{
+	datamodels.Offline:  {datamodels.WaitACK, datamodels.Err},
+	datamodels.Err:      {datamodels.Offline},
+	datamodels.Free:     {datamodels.WaitOK, datamodels.WaitACK},
+	datamodels.WaitOK:   {datamodels.Moving, datamodels.Free},
+	datamodels.Moving:   {datamodels.WaitACK},
+	datamodels.Occupied: {datamodels.WaitACK},
+	datamodels.Billing:  {datamodels.WaitACK},
+	datamodels.WaitACK:  {datamodels.Free, datamodels.Offline, datamodels.Occupied, datamodels.Billing},
+}
+
+// rideTransitions : Transitions autorisées de l'état de la course en cours
+var rideTransitions = map[datamodels.RideState][]datamodels.RideState{
+	noRide:                     {datamodels.Pending, datamodels.Booked, datamodels.Started, datamodels.Approach},
+	datamodels.Pending:         {datamodels.Booked, datamodels.Started, datamodels.Approach, datamodels.Cancelled},
+	datamodels.Booked:          {datamodels.Started, datamodels.Approach, datamodels.Cancelled},
+	datamodels.Started:         {datamodels.Approach, datamodels.Cancelled},
+	datamodels.Approach:        {datamodels.Delayed, datamodels.Waiting, datamodels.PickUpPassenger, datamodels.Cancelled},
+	datamodels.Delayed:         {datamodels.Approach, datamodels.Waiting, datamodels.PickUpPassenger, datamodels.Cancelled},
+	datamodels.Waiting:         {datamodels.PickUpPassenger, datamodels.Cancelled},
+	datamodels.PickUpPassenger: {datamodels.PendingPayment},
+	datamodels.PendingPayment:  {datamodels.Ended},
+	datamodels.Ended:           {noRide},
+	datamodels.Cancelled:       {noRide},
+}
+
+// rideStatesByTaximeter : Etats de course compatibles avec un état stable du driver.
+// Les états d'attente (WaitOK, WaitACK) et Err acceptent toutes les courses.
+var rideStatesByTaximeter = map[datamodels.TaximeterState][]datamodels.RideState{
+	datamodels.Offline:  {noRide},
+	datamodels.Free:     {noRide},
+	datamodels.Moving:   {noRide, datamodels.Pending, datamodels.Booked, datamodels.Started, datamodels.Approach, datamodels.Delayed, datamodels.Waiting},
+	datamodels.Occupied: {datamodels.Approach, datamodels.PickUpPassenger},
+	datamodels.Billing:  {datamodels.PickUpPassenger, datamodels.PendingPayment, datamodels.Ended, datamodels.Cancelled},
+}
+
+func taximeterAllowed(from, to datamodels.TaximeterState) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range taximeterTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func rideAllowed(from, to datamodels.RideState) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range rideTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+func combinationAllowed(taxi datamodels.TaximeterState, ride datamodels.RideState) bool {
+	states, has := rideStatesByTaximeter[taxi]
+	if !has {
+		return true
+	}
+	for _, s := range states {
+		if s == ride {
+			return true
+		}
+	}
+	return false
+}
+
+// transition : Passe le driver dans l'état to si la transition est autorisée.
+// L'appelant doit détenir d.mu.
+func (d *Driver) transition(to datamodels.TaximeterState, from trigger) bool {
+	if !taximeterAllowed(d.DriverState, to) {
+		d.hub.violations.record(d, "taximeter", taximeterName(d.DriverState), taximeterName(to), from)
+		return false
+	}
+	if d.DriverState != to && !combinationAllowed(to, d.Ride.State) {
+		d.hub.violations.record(d, "combination", taximeterName(to), rideName(d.Ride.State), from)
+		return false
+	}
+	if to != datamodels.WaitACK && to != datamodels.WaitOK {
+		d.resends = 0
+		d.stuck = false
+	}
+	d.DriverState = to
+	return true
+}
+
+// rideTransition : Change l'état de la course si la transition est autorisée.
+// L'appelant doit détenir d.mu.
+func (d *Driver) rideTransition(to datamodels.RideState, from trigger) bool {
+	if !rideAllowed(d.Ride.State, to) {
+		d.hub.violations.record(d, "ride", rideName(d.Ride.State), rideName(to), from)
+		return false
+	}
+	d.Ride.State = to
+	return true
+}
+
+func taximeterName(s datamodels.TaximeterState) string {
+	switch s {
+	case datamodels.Free:
+		return "Free"
+	case datamodels.Occupied:
+		return "Occupied"
+	case datamodels.Offline:
+		return "Offline"
+	case datamodels.Ghost:
+		return "Ghost"
+	case datamodels.Moving:
+		return "Moving"
+	case datamodels.WaitOK:
+		return "WaitOK"
+	case datamodels.WaitACK:
+		return "WaitACK"
+	case datamodels.Billing:
+		return "Billing"
+	case datamodels.Err:
+		return "Err"
+	}
+	return fmt.Sprintf("Taximeter(%d)", s)
+}
+
+func rideName(s datamodels.RideState) string {
+	switch s {
+	case noRide:
+		return "NoRide"
+	case datamodels.Pending:
+		return "Pending"
+	case datamodels.Booked:
+		return "Booked"
+	case datamodels.Started:
+		return "Started"
+	case datamodels.Approach:
+		return "Approach"
+	case datamodels.Delayed:
+		return "Delayed"
+	case datamodels.Waiting:
+		return "Waiting"
+	case datamodels.PickUpPassenger:
+		return "PickUpPassenger"
+	case datamodels.PendingPayment:
+		return "PendingPayment"
+	case datamodels.Ended:
+		return "Ended"
+	case datamodels.Cancelled:
+		return "Cancelled"
+	}
+	return fmt.Sprintf("Ride(%d)", s)
+}
+
+// violationKey : Regroupement des transitions illégales
+type violationKey struct {
+	Kind   string
+	From   string
+	To     string
+	Source string
+}
+
+// StateViolations : Transitions illégales comptées par message déclencheur
+type StateViolations struct {
+	mu     sync.Mutex
+	counts map[violationKey]int
+	last   map[violationKey]string
+}
+
+// NewStateViolations : Creation du compteur de transitions illégales
+func NewStateViolations() *StateViolations {
+	return &StateViolations{
+		counts: make(map[violationKey]int),
+		last:   make(map[violationKey]string),
+	}
+}
+
+func (v *StateViolations) record(d *Driver, kind, from, to string, t trigger) {
+	key := violationKey{Kind: kind, From: from, To: to, Source: t.Source}
+
+	v.mu.Lock()
+	v.counts[key]++
+	v.last[key] = fmt.Sprintf("%s (%d) %s", d.Name, d.ID, t)
+	v.mu.Unlock()
+
+	clog.Warn("Driver", "Transition", "%s (%d) illegal %s %s -> %s on %s", d.Name, d.ID, kind, from, to, t)
+	clog.File("STATE", d.Name, "illegal %s %s -> %s on %s", kind, from, to, t)
+}
+
+// Report : Transitions illégales, regroupées par message déclencheur
+func (v *StateViolations) Report(w io.Writer) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	reportTitle(w, "Driver state machine")
+	keys := make([]violationKey, 0, len(v.counts))
+	for k := range v.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Source != keys[j].Source {
+			return keys[i].Source < keys[j].Source
+		}
+		return v.counts[keys[i]] > v.counts[keys[j]]
+	})
+
+	if len(keys) == 0 {
+		fmt.Fprintf(w, "No illegal transition\n")
+	}
+	for _, k := range keys {
+		fmt.Fprintf(w, "%-28s %-11s %s -> %s : %d (last: %s)\n", k.Source, k.Kind, k.From, k.To, v.counts[k], v.last[k])
+	}
+	return len(keys) == 0
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// Classes de voie du modèle de vitesse
+const (
+	roadCentre   = "centre"
+	roadCity     = "city"
+	roadArterial = "arterial"
+)
+
+// Trajets suivis pour les ETA
+const (
+	legApproach = "approach"
+	legTrip     = "trip"
+)
+
+// predictStep : Pas en km de l'intégration de l'ETA le long du trajet
+const predictStep = 0.1
+
+var centreZones = map[string]bool{}
+
+// loadTraffic : Vérifie les courbes de congestion et les zones du centre
+func loadTraffic() {
+	t := conf.Traffic
+	if !trafficEnabled() {
+		if t.CentreSpeed > 0 || t.ArterialSpeed > 0 || len(t.CentreZones) > 0 {
+			clog.Warn("main", "Traffic", "CitySpeed is 0, traffic model disabled: CentreSpeed, ArterialSpeed and CentreZones are ignored")
+		}
+		return
+	}
+	for _, curve := range [][]float64{t.Congestion, t.CentreCongestion} {
+		if len(curve) != 0 && len(curve) != 24 {
+			clog.Fatal("main", "Traffic", fmt.Errorf("congestion curve needs 24 hourly factors, got %d", len(curve)))
+		}
+	}
+	for _, name := range t.CentreZones {
+		if zones.Named(name) == nil {
+			clog.Fatal("main", "Traffic", fmt.Errorf("unknown centre zone %q", name))
+		}
+		centreZones[name] = true
+	}
+	clog.Info("main", "Traffic", "Speeds %.0f / %.0f / %.0f km/h (centre / city / arterial)", t.CentreSpeed, t.CitySpeed, t.ArterialSpeed)
+}
+
+// trafficEnabled : Modèle de vitesse configuré, KmByBT par BT sinon
+func trafficEnabled() bool {
+	return conf.Traffic.CitySpeed > 0
+}
+
+// roadClass : Classe de voie au point c, travelled et remaining étant les km parcourus et restants du trajet
+func roadClass(c datamodels.Coordinates, travelled, remaining float64) string {
+	if len(centreZones) > 0 && centreZones[zoneOf(c)] {
+		return roadCentre
+	}
+	t := conf.Traffic
+	if t.ArterialSpeed > 0 && travelled > t.ArterialKm && remaining > t.ArterialKm {
+		return roadArterial
+	}
+	return roadCity
+}
+
+// speedAt : Vitesse en km/h pour la classe de voie à l'heure de simulation at
+func speedAt(class string, at time.Time) float64 {
+	t := conf.Traffic
+	speed, curve := t.CitySpeed, t.Congestion
+	switch class {
+	case roadCentre:
+		speed, curve = t.CentreSpeed, t.CentreCongestion
+		if speed <= 0 {
+			speed = t.CitySpeed
+		}
+	case roadArterial:
+		speed = t.ArterialSpeed
+	}
+	if len(curve) == 24 {
+		speed *= curve[at.Hour()]
+	}
+	return math.Max(speed, 1)
+}
+
+// tickHours : Durée simulée d'un BT en heures
+func tickHours() float64 {
+	return float64(conf.Bench.BaseTimer) * simSpeed / 3600
+}
+
+// stepKm : Distance parcourue pendant un BT depuis la position du driver. L'appelant doit détenir d.mu.
+func (d *Driver) stepKm() float64 {
+	if !trafficEnabled() {
+		return conf.Bench.KmByBT
+	}
+	class := roadClass(d.Coord, d.leg.Km-d.ToDest, d.ToDest)
+	return speedAt(class, simNow()) * tickHours()
+}
+
+// predictMinutes : ETA en minutes simulées de from à to, aux vitesses de l'heure at
+func predictMinutes(from, to datamodels.Coordinates, at time.Time) float64 {
+	km := geoloc.DistanceAccurate(from.Latitude, from.Longitude, to.Latitude, to.Longitude) / 1000
+	if !trafficEnabled() {
+		if conf.Bench.KmByBT <= 0 {
+			return 0
+		}
+		return math.Ceil(km/conf.Bench.KmByBT) * tickHours() * 60
+	}
+
+	hours := 0.0
+	for done := 0.0; done < km; done += predictStep {
+		step := math.Min(predictStep, km-done)
+		lat, lng := geoloc.Intermediate(from.Latitude, from.Longitude, to.Latitude, to.Longitude, (done+step/2)/km)
+		class := roadClass(datamodels.Coordinates{Latitude: lat, Longitude: lng}, done, km-done)
+		hours += step / speedAt(class, at)
+	}
+	return hours * 60
+}
+
+// travelLeg : Trajet en cours, approche ou course
+type travelLeg struct {
+	Kind      string
+	Start     time.Time // Heure de simulation
+	Km        float64
+	Predicted float64 // ETA en minutes simulées au départ
+}
+
+// startLeg : Départ vers target. L'appelant doit détenir d.mu.
+func (d *Driver) startLeg(kind string, target datamodels.Coordinates) {
+	now := simNow()
+	d.leg = travelLeg{
+		Kind:      kind,
+		Start:     now,
+		Km:        d.ToDest,
+		Predicted: predictMinutes(d.Coord, target, now),
+	}
+}
+
+// endLeg : Arrivée au bout du trajet en cours
+func (d *Driver) endLeg() {
+	d.mu.Lock()
+	leg := d.leg
+	d.leg = travelLeg{}
+	d.mu.Unlock()
+
+	if leg.Kind != "" {
+		d.hub.travel.arrived(leg, simNow())
+	}
+}
+
+// legTimes : Trajets terminés d'un type
+type legTimes struct {
+	count     int
+	gaps      samples // Ecart en % entre durée réelle et ETA
+	speeds    samples // Vitesse moyenne en km/h
+	predicted samples
+	actual    samples
+}
+
+// TravelTimes : ETA du modèle au départ contre durée simulée des trajets.
+// Les deux viennent du même modèle de vitesse : c'est un contrôle du modèle, pas de l'ETA du serveur.
+// Le protocole ne transmet aucune ETA ni horodatage serveur (RideData n'a que startDate),
+// la validation des estimations du serveur n'est donc pas possible depuis le bench.
+type TravelTimes struct {
+	mu     sync.Mutex
+	legs   map[string]*legTimes
+	byHour map[int]*samples // Vitesse moyenne par heure de départ simulée
+}
+
+// NewTravelTimes : Creation du suivi des ETA
+func NewTravelTimes() *TravelTimes {
+	return &TravelTimes{
+		legs:   make(map[string]*legTimes),
+		byHour: make(map[int]*samples),
+	}
+}
+
+// arrived : Trajet terminé à l'heure de simulation at
+func (t *TravelTimes) arrived(leg travelLeg, at time.Time) {
+	minutes := at.Sub(leg.Start).Minutes()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, has := t.legs[leg.Kind]
+	if !has {
+		l = &legTimes{}
+		t.legs[leg.Kind] = l
+	}
+	l.count++
+	l.predicted = append(l.predicted, leg.Predicted)
+	l.actual = append(l.actual, minutes)
+	if leg.Predicted > 0 {
+		l.gaps = append(l.gaps, drift(minutes, leg.Predicted))
+	}
+	if minutes > 0 {
+		speed := leg.Km / (minutes / 60)
+		l.speeds = append(l.speeds, speed)
+
+		h, has := t.byHour[leg.Start.Hour()]
+		if !has {
+			h = &samples{}
+			t.byHour[leg.Start.Hour()] = h
+		}
+		*h = append(*h, speed)
+	}
+}
+
+// Report : ETA du modèle et vitesses des approches et des courses
+func (t *TravelTimes) Report(w io.Writer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	reportTitle(w, "Travel times (model self-check)")
+	if trafficEnabled() {
+		fmt.Fprintf(w, "Traffic model: city %.0f km/h, centre %.0f km/h, arterial %.0f km/h\n",
+			conf.Traffic.CitySpeed, conf.Traffic.CentreSpeed, conf.Traffic.ArterialSpeed)
+	} else {
+		fmt.Fprintf(w, "No traffic model, %.1f km per BT\n", conf.Bench.KmByBT)
+	}
+	fmt.Fprintf(w, "Bench ETA vs simulated travel only\n")
+	fmt.Fprintf(w, "Server ETA validation: not possible, the protocol carries no server ETA or server timestamps\n")
+
+	for _, kind := range []string{legApproach, legTrip} {
+		l, has := t.legs[kind]
+		if !has {
+			continue
+		}
+		fmt.Fprintf(w, "%s: %d legs\n", kind, l.count)
+		fmt.Fprintf(w, "  Model ETA %s\n", l.predicted.summary("min"))
+		fmt.Fprintf(w, "  Simulated %s\n", l.actual.summary("min"))
+		if len(l.gaps) > 0 {
+			fmt.Fprintf(w, "  Simulated vs model ETA %s\n", l.gaps.summary("%"))
+		}
+		if len(l.speeds) > 0 {
+			fmt.Fprintf(w, "  Speed %s\n", l.speeds.summary("km/h"))
+		}
+	}
+
+	if len(t.byHour) > 0 {
+		fmt.Fprintf(w, "Mean speed by simulated departure hour:\n")
+		for h := 0; h < 24; h++ {
+			if s, has := t.byHour[h]; has {
+				fmt.Fprintf(w, "  %02dh : %.1f km/h (%d legs)\n", h, s.mean(), len(*s))
+			}
+		}
+	}
+	return true
+}
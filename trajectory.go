@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// Formats d'export des trajectoires
+const (
+	exportGPX     = "gpx"
+	exportKML     = "kml"
+	exportGeoJSON = "geojson"
+)
+
+// Evénements de course placés sur les trajectoires
+const (
+	eventPickUp  = "pickup"
+	eventDropOff = "dropoff"
+)
+
+// trackPoint : Position envoyée au serveur
+type trackPoint struct {
+	At    time.Time
+	Coord datamodels.Coordinates
+}
+
+// rideEvent : Prise en charge ou dépose d'une course
+type rideEvent struct {
+	At         time.Time
+	Kind       string
+	ExternalID string
+	Coord      datamodels.Coordinates
+}
+
+// driverTrack : Trajectoire d'un driver
+type driverTrack struct {
+	ID     int
+	Name   string
+	Group  string
+	Points []trackPoint
+	Events []rideEvent
+}
+
+// Trajectories : Positions envoyées et événements de course à l'heure de simulation, exportés en fin de bench
+type Trajectories struct {
+	mu     sync.Mutex
+	tracks map[int]*driverTrack
+}
+
+// NewTrajectories : Creation de l'enregistrement des trajectoires
+func NewTrajectories() *Trajectories {
+	return &Trajectories{
+		tracks: make(map[int]*driverTrack),
+	}
+}
+
+// exportEnabled : Un répertoire d'export est configuré, rien n'est enregistré sinon
+func exportEnabled() bool {
+	return conf.Export.Dir != ""
+}
+
+func (t *Trajectories) track(d *Driver) *driverTrack {
+	tr, has := t.tracks[d.ID]
+	if !has {
+		tr = &driverTrack{ID: d.ID, Name: d.Name, Group: d.Group.Name}
+		t.tracks[d.ID] = tr
+	}
+	return tr
+}
+
+// sent : Positions envoyées par sendCoord, dans l'ordre d'envoi, chacune à l'heure de sa lecture
+func (t *Trajectories) sent(d *Driver, fixes []geoloc.Fix) {
+	if !exportEnabled() || len(fixes) == 0 {
+		return
+	}
+	t.mu.Lock()
+	tr := t.track(d)
+	for _, f := range fixes {
+		tr.Points = append(tr.Points, trackPoint{At: f.At, Coord: datamodels.Coordinates{Latitude: f.Latitude, Longitude: f.Longitude}})
+	}
+	t.mu.Unlock()
+}
+
+// event : Prise en charge ou dépose d'une course
+func (t *Trajectories) event(d *Driver, kind string, externalID string, c datamodels.Coordinates) {
+	if !exportEnabled() {
+		return
+	}
+	t.mu.Lock()
+	tr := t.track(d)
+	tr.Events = append(tr.Events, rideEvent{At: simNow(), Kind: kind, ExternalID: externalID, Coord: c})
+	t.mu.Unlock()
+}
+
+// sorted : Trajectoires par ID de driver
+func (t *Trajectories) sorted() []*driverTrack {
+	tracks := make([]*driverTrack, 0, len(t.tracks))
+	for _, tr := range t.tracks {
+		tracks = append(tracks, tr)
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+	return tracks
+}
+
+// export : Ecrit les trajectoires dans les formats configurés, en fin de bench.
+// Les erreurs sont journalisées, elles ne changent pas le résultat du bench.
+func (t *Trajectories) export() {
+	if !exportEnabled() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tracks := t.sorted()
+	points, events := 0, 0
+	for _, tr := range tracks {
+		points += len(tr.Points)
+		events += len(tr.Events)
+	}
+
+	if err := os.MkdirAll(conf.Export.Dir, 0755); err != nil {
+		clog.Error("main", "Export", "%s", err)
+		return
+	}
+
+	formats := conf.Export.Formats
+	if len(formats) == 0 {
+		formats = []string{exportGPX, exportKML, exportGeoJSON}
+	}
+	for _, format := range formats {
+		var err error
+		switch format {
+		case exportGPX:
+			err = writeGPX(conf.Export.Dir, tracks)
+		case exportKML:
+			err = writeExport(filepath.Join(conf.Export.Dir, "trajectories.kml"), tracks, writeKML)
+		case exportGeoJSON:
+			err = writeExport(filepath.Join(conf.Export.Dir, "trajectories.geojson"), tracks, writeGeoJSON)
+		default:
+			err = fmt.Errorf("unknown format %q", format)
+		}
+		if err != nil {
+			clog.Error("main", "Export", "%s: %s", format, err)
+			continue
+		}
+		clog.Info("main", "Export", "%s: %d drivers, %d positions, %d ride events to %s", format, len(tracks), points, events, conf.Export.Dir)
+	}
+}
+
+// writeExport : Crée le fichier path et y écrit les trajectoires
+func writeExport(path string, tracks []*driverTrack, write func(io.Writer, []*driverTrack) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, tracks); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+////////////////
+// GPX
+////////////////
+
+type gpxPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time,omitempty"`
+	Name string  `xml:"name,omitempty"`
+	Desc string  `xml:"desc,omitempty"`
+}
+
+type gpxFile struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Track     struct {
+		Name    string     `xml:"name"`
+		Segment []gpxPoint `xml:"trkseg>trkpt"`
+	} `xml:"trk"`
+}
+
+// writeGPX : Un fichier GPX par driver, trace des positions et waypoints des courses
+func writeGPX(dir string, tracks []*driverTrack) error {
+	for _, tr := range tracks {
+		g := gpxFile{Version: "1.1", Creator: "bench_dispatch", Xmlns: "http://www.topografix.com/GPX/1/1"}
+		g.Track.Name = fmt.Sprintf("%s (%d)", tr.Name, tr.ID)
+		for _, p := range tr.Points {
+			g.Track.Segment = append(g.Track.Segment, gpxPoint{Lat: p.Coord.Latitude, Lon: p.Coord.Longitude, Time: p.At.UTC().Format(time.RFC3339)})
+		}
+		for _, e := range tr.Events {
+			g.Waypoints = append(g.Waypoints, gpxPoint{
+				Lat:  e.Coord.Latitude,
+				Lon:  e.Coord.Longitude,
+				Time: e.At.UTC().Format(time.RFC3339),
+				Name: e.Kind,
+				Desc: e.ExternalID,
+			})
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("driver-%03d.gpx", tr.ID))
+		err := writeExport(path, nil, func(w io.Writer, _ []*driverTrack) error {
+			io.WriteString(w, xml.Header)
+			enc := xml.NewEncoder(w)
+			enc.Indent("", "  ")
+			return enc.Encode(g)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+////////////////
+// KML
+////////////////
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlGeometry struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPlacemark struct {
+	Name       string        `xml:"name"`
+	TimeStamp  *kmlTimeStamp `xml:"TimeStamp,omitempty"`
+	Point      *kmlGeometry  `xml:"Point,omitempty"`
+	LineString *kmlGeometry  `xml:"LineString,omitempty"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlFile struct {
+	XMLName xml.Name    `xml:"kml"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Name    string      `xml:"Document>name"`
+	Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+func kmlCoord(c datamodels.Coordinates) string {
+	return fmt.Sprintf("%f,%f", c.Longitude, c.Latitude)
+}
+
+// writeKML : Un dossier par driver, trajectoire et événements des courses
+func writeKML(w io.Writer, tracks []*driverTrack) error {
+	k := kmlFile{Xmlns: "http://www.opengis.net/kml/2.2", Name: "bench_dispatch"}
+	for _, tr := range tracks {
+		folder := kmlFolder{Name: fmt.Sprintf("%s (%d) %s", tr.Name, tr.ID, tr.Group)}
+		if len(tr.Points) > 1 {
+			line := ""
+			for i, p := range tr.Points {
+				if i > 0 {
+					line += " "
+				}
+				line += kmlCoord(p.Coord)
+			}
+			folder.Placemarks = append(folder.Placemarks, kmlPlacemark{Name: "track", LineString: &kmlGeometry{line}})
+		}
+		for _, e := range tr.Events {
+			folder.Placemarks = append(folder.Placemarks, kmlPlacemark{
+				Name:      fmt.Sprintf("%s %s", e.Kind, e.ExternalID),
+				TimeStamp: &kmlTimeStamp{e.At.UTC().Format(time.RFC3339)},
+				Point:     &kmlGeometry{kmlCoord(e.Coord)},
+			})
+		}
+		k.Folders = append(k.Folders, folder)
+	}
+
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(k)
+}
+
+////////////////
+// GeoJSON
+////////////////
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONOut struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+func geoJSONCoord(c datamodels.Coordinates) [2]float64 {
+	return [2]float64{c.Longitude, c.Latitude}
+}
+
+// writeGeoJSON : Une LineString par driver et un Point par événement de course
+func writeGeoJSON(w io.Writer, tracks []*driverTrack) error {
+	features := []geoJSONOut{}
+	for _, tr := range tracks {
+		if len(tr.Points) > 1 {
+			line := make([][2]float64, 0, len(tr.Points))
+			for _, p := range tr.Points {
+				line = append(line, geoJSONCoord(p.Coord))
+			}
+			features = append(features, geoJSONOut{
+				Type:     "Feature",
+				Geometry: geoJSONGeometry{Type: "LineString", Coordinates: line},
+				Properties: map[string]interface{}{
+					"driver": tr.ID,
+					"name":   tr.Name,
+					"group":  tr.Group,
+					"start":  tr.Points[0].At.Format(time.RFC3339),
+					"end":    tr.Points[len(tr.Points)-1].At.Format(time.RFC3339),
+				},
+			})
+		}
+		for _, e := range tr.Events {
+			features = append(features, geoJSONOut{
+				Type:     "Feature",
+				Geometry: geoJSONGeometry{Type: "Point", Coordinates: geoJSONCoord(e.Coord)},
+				Properties: map[string]interface{}{
+					"driver": tr.ID,
+					"kind":   e.Kind,
+					"ride":   e.ExternalID,
+					"time":   e.At.Format(time.RFC3339),
+				},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(struct {
+		Type     string       `json:"type"`
+		Features []geoJSONOut `json:"features"`
+	}{"FeatureCollection", features})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+)
+
+// Modes de récupération d'un driver bloqué
+const (
+	recoverNone   = "none"
+	recoverResend = "resend"
+	recoverReset  = "reset"
+)
+
+var watchdogTrigger = trigger{Source: "Watchdog"}
+
+// stuckEvent : Requête restée sans réponse
+type stuckEvent struct {
+	DriverID int
+	Name     string
+	State    datamodels.TaximeterState
+	ReqID    int
+	Method   string
+	Ticks    int
+	Action   string
+}
+
+// StuckDrivers : Drivers bloqués en WaitACK / WaitOK
+type StuckDrivers struct {
+	mu     sync.Mutex
+	events []stuckEvent
+}
+
+// NewStuckDrivers : Creation du suivi des drivers bloqués
+func NewStuckDrivers() *StuckDrivers {
+	return &StuckDrivers{}
+}
+
+func (s *StuckDrivers) record(e stuckEvent) {
+	s.mu.Lock()
+	s.events = append(s.events, e)
+	s.mu.Unlock()
+
+	clog.Warn("Driver", "Watchdog", "%s (%d) stuck in %s for %d BT, request %d %s, %s", e.Name, e.DriverID, taximeterName(e.State), e.Ticks, e.ReqID, e.Method, e.Action)
+	clog.File("STUCK", e.Name, "%s %d BT | %d | %s | %s", taximeterName(e.State), e.Ticks, e.ReqID, e.Method, e.Action)
+}
+
+func waitTimeout(state datamodels.TaximeterState) int {
+	switch state {
+	case datamodels.WaitACK:
+		return conf.Watchdog.WaitACKTimeout
+	case datamodels.WaitOK:
+		return conf.Watchdog.WaitOKTimeout
+	}
+	return 0
+}
+
+// watchdog : Vérifie qu'un driver n'attend pas une réponse depuis trop longtemps.
+// Retourne le nouveau compteur de BT passés dans l'état.
+func (d *Driver) watchdog(state datamodels.TaximeterState, ticks int) int {
+	timeout := waitTimeout(state)
+	if timeout <= 0 || ticks < timeout {
+		return ticks
+	}
+
+	d.mu.Lock()
+	d.stuck = true
+	req := d.waitReq
+	resend := conf.Watchdog.Recover == recoverResend && d.resends < conf.Watchdog.MaxResend
+	reset := conf.Watchdog.Recover == recoverReset || (conf.Watchdog.Recover == recoverResend && !resend)
+	if resend {
+		d.resends++
+	}
+	d.mu.Unlock()
+
+	event := stuckEvent{
+		DriverID: d.ID,
+		Name:     d.Name,
+		State:    state,
+		ReqID:    req.ID,
+		Method:   req.Method,
+		Ticks:    ticks,
+		Action:   "flagged",
+	}
+
+	switch {
+	case resend && req.Method != "":
+		event.Action = "resent"
+		d.hub.stuck.record(event)
+		d.mu.Lock()
+		d.waitReq = d.writeRequest(req.Method, req.Params)
+		d.mu.Unlock()
+	case reset:
+		event.Action = "reset to Free, ChangeTaximeterState sent"
+		d.hub.stuck.record(event)
+		d.mu.Lock()
+		d.Ride = datamodels.RideData{}
+		d.ToDest = 0
+		freed := d.transition(datamodels.Free, watchdogTrigger)
+		d.mu.Unlock()
+		// Le serveur doit aussi repasser le driver à Free, sinon il reste occupé de son côté
+		if freed {
+			d.requestChangeTaximeterStateReponse(datamodels.Free, watchdogTrigger)
+		}
+	default:
+		d.hub.stuck.record(event)
+	}
+	return 0
+}
+
+// Report : Requêtes sans réponse par état et méthode
+func (s *StuckDrivers) Report(w io.Writer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reportTitle(w, "Stuck drivers watchdog")
+
+	type key struct {
+		State  datamodels.TaximeterState
+		Method string
+	}
+	counts := make(map[key]int)
+	drivers := make(map[int]bool)
+	for _, e := range s.events {
+		counts[key{e.State, e.Method}]++
+		drivers[e.DriverID] = true
+	}
+	keys := make([]key, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return counts[keys[i]] > counts[keys[j]] })
+
+	// Le seuil porte sur les drivers, un driver peut se bloquer plusieurs fois
+	ok := len(drivers) <= conf.Thresholds.MaxStuck
+	fmt.Fprintf(w, "%s stuck drivers: %d (max %d), %d events\n", passFail(ok), len(drivers), conf.Thresholds.MaxStuck, len(s.events))
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %-8s %-22s : %d\n", taximeterName(k.State), k.Method, counts[k])
+	}
+	for i, e := range s.events {
+		if i == 20 {
+			fmt.Fprintf(w, "  ... %d more\n", len(s.events)-i)
+			break
+		}
+		fmt.Fprintf(w, "  %s (%d) %s %d BT, request %d %s, %s\n", e.Name, e.DriverID, taximeterName(e.State), e.Ticks, e.ReqID, e.Method, e.Action)
+	}
+	return ok
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"bench_dispatch/clog"
+	"bench_dispatch/datamodels"
+	"bench_dispatch/geoloc"
+)
+
+// outsideZones : Nom de regroupement des points hors de toute zone
+const outsideZones = "(outside)"
+
+var (
+	zones         geoloc.Zones
+	zoneAddresses map[string][]datamodels.Address
+)
+
+// loadZones : Charge les zones GeoJSON et répartit les adresses du CSV par zone
+func loadZones() {
+	if conf.Zones.File == "" {
+		return
+	}
+	zs, err := geoloc.LoadZones(conf.Zones.File)
+	if err != nil {
+		clog.Fatal("main", "Zones", err)
+	}
+	zones = zs
+
+	zoneAddresses = make(map[string][]datamodels.Address)
+	for _, a := range address {
+		if z := zones.Find(a.Coord.Latitude, a.Coord.Longitude); z != nil {
+			zoneAddresses[z.Name] = append(zoneAddresses[z.Name], a)
+		}
+	}
+	for _, z := range zones {
+		if len(zoneAddresses[z.Name]) == 0 {
+			clog.Warn("main", "Zones", "zone %s (%s) contains no address", z.Name, z.Kind)
+		}
+	}
+	clog.Info("main", "Zones", "%d zones loaded from %s", len(zones), conf.Zones.File)
+}
+
+// zoneOf : Nom de la zone contenant le point
+func zoneOf(c datamodels.Coordinates) string {
+	if z := zones.Find(c.Latitude, c.Longitude); z != nil {
+		return z.Name
+	}
+	return outsideZones
+}
+
+// addressInZone : Adresse aléatoire de la zone, n'importe où si la zone n'a pas d'adresse
+func addressInZone(name string) datamodels.Address {
+	list := zoneAddresses[name]
+	if len(list) == 0 {
+		return getNewAdress()
+	}
+	return list[rand.Intn(len(list))]
+}
+
+// pickZone : Tire une zone pondérée par sa demande, parmi celles qui ont des adresses
+func pickZone(outOfService bool) *geoloc.Zone {
+	var candidates geoloc.Zones
+	total := 0.0
+	for _, z := range zones {
+		if (z.Kind == geoloc.ZoneOutOfService) != outOfService || len(zoneAddresses[z.Name]) == 0 {
+			continue
+		}
+		candidates = append(candidates, z)
+		total += z.Demand
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	n := rand.Float64() * total
+	for _, z := range candidates {
+		if n < z.Demand {
+			return z
+		}
+		n -= z.Demand
+	}
+	return candidates[len(candidates)-1]
+}
+
+// pickupAddress : Prise en charge d'une nouvelle course, selon le scénario, la demande par zone ou la densité
+func pickupAddress() datamodels.Address {
+	if conf.Bench.Scenario == scenarioHerd {
+		return scenarioAddress()
+	}
+	if len(zones) > 0 {
+		outOfService := rand.Intn(100) < conf.Zones.OutOfServicePercent
+		if z := pickZone(outOfService); z != nil {
+			return chooseAddress(zoneAddresses[z.Name], func(c datamodels.Coordinates) bool { return zoneOf(c) == z.Name })
+		}
+	}
+	return chooseAddress(nil, nil)
+}
+
+// zoneCounts : Offre et demande d'une zone
+type zoneCounts struct {
+	supply     int // Positions envoyées depuis la zone
+	rides      []string
+	oosOffered int // NewRide reçus pour une course hors service
+}
+
+// ZoneStats : Offre, demande et dispatch par zone
+type ZoneStats struct {
+	mu        sync.Mutex
+	zones     map[string]*zoneCounts
+	rideZones map[string]string
+	timelines *RideTimelines
+	quality   *DispatchQuality
+}
+
+// NewZoneStats : Creation des statistiques par zone
+func NewZoneStats(timelines *RideTimelines, quality *DispatchQuality) *ZoneStats {
+	return &ZoneStats{
+		zones:     make(map[string]*zoneCounts),
+		rideZones: make(map[string]string),
+		timelines: timelines,
+		quality:   quality,
+	}
+}
+
+func (s *ZoneStats) zone(name string) *zoneCounts {
+	c, has := s.zones[name]
+	if !has {
+		c = &zoneCounts{}
+		s.zones[name] = c
+	}
+	return c
+}
+
+// positioned : Position envoyée par un driver
+func (s *ZoneStats) positioned(c datamodels.Coordinates) {
+	if len(zones) == 0 {
+		return
+	}
+	name := zoneOf(c)
+	s.mu.Lock()
+	s.zone(name).supply++
+	s.mu.Unlock()
+}
+
+// created : Course créée avec sa prise en charge
+func (s *ZoneStats) created(ride datamodels.RideData) {
+	if len(zones) == 0 {
+		return
+	}
+	name := zoneOf(ride.FromAddress.Coord)
+	s.mu.Lock()
+	s.rideZones[ride.ExternalID] = name
+	c := s.zone(name)
+	c.rides = append(c.rides, ride.ExternalID)
+	s.mu.Unlock()
+}
+
+// notified : NewRide reçu, une course hors service ne doit jamais être proposée
+func (s *ZoneStats) notified(externalID string) {
+	s.mu.Lock()
+	if name, has := s.rideZones[externalID]; has {
+		if z := zones.Named(name); z != nil && z.Kind == geoloc.ZoneOutOfService {
+			s.zone(name).oosOffered++
+		}
+	}
+	s.mu.Unlock()
+}
+
+// Report : Offre, demande, latence de dispatch et qualité par zone
+func (s *ZoneStats) Report(w io.Writer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reportTitle(w, "Zones")
+	if len(zones) == 0 {
+		fmt.Fprintf(w, "No zone file configured\n")
+		return true
+	}
+
+	names := make([]string, 0, len(s.zones))
+	supply := 0
+	for name, c := range s.zones {
+		names = append(names, name)
+		supply += c.supply
+	}
+	sort.Strings(names)
+
+	oos := 0
+	for _, name := range names {
+		c := s.zones[name]
+		kind := ""
+		if z := zones.Named(name); z != nil {
+			kind = z.Kind
+		}
+
+		var latencies durations
+		nearest, ranked := 0, 0
+		for _, ext := range c.rides {
+			if d, ok := s.timelines.span(ext, phaseCreateRide, phaseNewRide); ok {
+				latencies = append(latencies, d)
+			}
+			if rank, ok := s.quality.rank(ext); ok {
+				ranked++
+				if rank == 1 {
+					nearest++
+				}
+			}
+		}
+
+		share := 0.0
+		if supply > 0 {
+			share = 100 * float64(c.supply) / float64(supply)
+		}
+		fmt.Fprintf(w, "Zone %s %s: supply %.1f%%, rides %d, dispatched %d\n", name, kind, share, len(c.rides), len(latencies))
+		if len(latencies) > 0 {
+			fmt.Fprintf(w, "  Time to dispatch %s\n", latencies.summary())
+		}
+		if ranked > 0 {
+			fmt.Fprintf(w, "  Nearest driver chosen %.1f%% of %d\n", 100*float64(nearest)/float64(ranked), ranked)
+		}
+		oos += c.oosOffered
+	}
+
+	fmt.Fprintf(w, "%s NewRide for out of service pickups: %d\n", passFail(oos == 0), oos)
+	return oos == 0
+}